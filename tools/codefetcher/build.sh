@@ -11,6 +11,7 @@ DISTS=(
 )
 
 OUTPUT_DIR=build
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
 
 for dist in ${DISTS[@]}; do
   os=$(echo $dist | cut -d '/' -f 1)
@@ -22,7 +23,7 @@ for dist in ${DISTS[@]}; do
   fi
 
   echo -n "Building $(basename $output_file)... "
-  env GOOS=${os} GOARCH=${arch} CGO_ENABLED=0 go build -o ${output_file} cmd/codefetcher/main.go
+  env GOOS=${os} GOARCH=${arch} CGO_ENABLED=0 go build -ldflags "-X main.version=${VERSION}" -o ${output_file} ./cmd/codefetcher
   if [ $? -eq 0 ]; then
     echo "OK"
   fi
@@ -0,0 +1,230 @@
+// Package classify identifies the language of code files with a naive Bayes classifier over
+// token n-grams, trained on the code table of a codefetcher database.
+package classify
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultNGram     = 2
+	DefaultMaxTokens = 1000
+	DefaultMinCount  = 2
+)
+
+var (
+	ErrorEmptyModel = errors.New("model has no training data")
+)
+
+// Tokenize splits code into identifiers, numbers and punctuation, numbers are replaced by "0".
+// At most maxTokens tokens are returned, all if maxTokens is 0.
+func Tokenize(code []byte, maxTokens int) []string {
+	var tokens []string
+	for i := 0; i < len(code) && (maxTokens <= 0 || len(tokens) < maxTokens); {
+		c := code[i]
+		switch {
+		case isIdentifierStart(c):
+			start := i
+			for i < len(code) && (isIdentifierStart(code[i]) || isDigit(code[i])) {
+				i++
+			}
+			tokens = append(tokens, string(code[start:i]))
+		case isDigit(c):
+			for i < len(code) && (isDigit(code[i]) || isIdentifierStart(code[i]) || code[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, "0")
+		case c <= ' ' || c >= 0x80: // whitespace, control characters and non-ASCII text
+			i++
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || c == '@' || c == '#' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// features returns the token n-grams of code, n from 1 to ngram
+func features(code []byte, ngram, maxTokens int) []string {
+	tokens := Tokenize(code, maxTokens)
+	var result []string
+	for n := 1; n <= ngram; n++ {
+		for i := 0; i+n <= len(tokens); i++ {
+			result = append(result, strings.Join(tokens[i:i+n], " "))
+		}
+	}
+	return result
+}
+
+// Model multinomial naive Bayes model with Laplace smoothing, languages are stored as
+// Language.String() of the codefetcher package
+type Model struct {
+	NGram     int                       `json:"ngram"`
+	MaxTokens int                       `json:"max_tokens"`
+	Documents map[string]int            `json:"documents"` // training files by language
+	Features  map[string]map[string]int `json:"features"`  // n-gram counts by language
+	Totals    map[string]int            `json:"totals"`    // total n-gram count by language
+
+	vocabulary int // distinct n-grams of all languages
+}
+
+// NewModel returns an empty model using n-grams up to ngram tokens of the first maxTokens tokens
+// of each file, 0 for DefaultNGram and all tokens
+func NewModel(ngram, maxTokens int) *Model {
+	if ngram <= 0 {
+		ngram = DefaultNGram
+	}
+	return &Model{
+		NGram:     ngram,
+		MaxTokens: maxTokens,
+		Documents: make(map[string]int),
+		Features:  make(map[string]map[string]int),
+		Totals:    make(map[string]int),
+	}
+}
+
+// Add trains the model with a code file of language
+func (m *Model) Add(language string, code []byte) {
+	counts, ok := m.Features[language]
+	if !ok {
+		counts = make(map[string]int)
+		m.Features[language] = counts
+	}
+	for _, feature := range features(code, m.NGram, m.MaxTokens) {
+		if counts[feature] == 0 && !m.known(feature) {
+			m.vocabulary++
+		}
+		counts[feature]++
+		m.Totals[language]++
+	}
+	m.Documents[language]++
+}
+
+// known returns true if feature has been seen in any language
+func (m *Model) known(feature string) bool {
+	for _, counts := range m.Features {
+		if counts[feature] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Model) countVocabulary() {
+	vocabulary := make(map[string]bool)
+	for _, counts := range m.Features {
+		for feature := range counts {
+			vocabulary[feature] = true
+		}
+	}
+	m.vocabulary = len(vocabulary)
+}
+
+// Prune removes n-grams seen less than minCount times in a language, which keeps the model small
+func (m *Model) Prune(minCount int) {
+	for language, counts := range m.Features {
+		for feature, count := range counts {
+			if count < minCount {
+				delete(counts, feature)
+				m.Totals[language] -= count
+			}
+		}
+	}
+	m.countVocabulary()
+}
+
+// Languages returns the languages known to the model, sorted by name
+func (m *Model) Languages() []string {
+	var languages []string
+	for language := range m.Documents {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Prediction language of a code file, Confidence is the posterior probability of Language
+type Prediction struct {
+	Language   string             `json:"language"`
+	Confidence float64            `json:"confidence"`
+	Scores     map[string]float64 `json:"scores"` // posterior probability by language
+}
+
+// Predict returns the most likely language of code, it is safe to call Predict concurrently
+func (m *Model) Predict(code []byte) (Prediction, error) {
+	if len(m.Documents) == 0 {
+		return Prediction{}, ErrorEmptyModel
+	}
+	documents := 0
+	for _, count := range m.Documents {
+		documents += count
+	}
+
+	features := features(code, m.NGram, m.MaxTokens)
+	logScores := make(map[string]float64, len(m.Documents))
+	best := math.Inf(-1)
+	prediction := Prediction{Scores: make(map[string]float64, len(m.Documents))}
+	for _, language := range m.Languages() {
+		score := math.Log(float64(m.Documents[language]) / float64(documents))
+		counts, denominator := m.Features[language], float64(m.Totals[language]+m.vocabulary+1) // +1 for unseen n-grams
+		for _, feature := range features {
+			score += math.Log(float64(counts[feature]+1) / denominator)
+		}
+		logScores[language] = score
+		if score > best {
+			best, prediction.Language = score, language
+		}
+	}
+
+	// posterior probabilities, normalized relative to the best score to avoid underflow
+	sum := 0.0
+	for language, score := range logScores {
+		prediction.Scores[language] = math.Exp(score - best)
+		sum += prediction.Scores[language]
+	}
+	for language := range prediction.Scores {
+		prediction.Scores[language] /= sum
+	}
+	prediction.Confidence = prediction.Scores[prediction.Language]
+	return prediction, nil
+}
+
+// Save writes the model as gzip compressed JSON
+func (m *Model) Save(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(m); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// Load reads a model written by Save
+func Load(r io.Reader) (*Model, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	m := NewModel(0, 0)
+	if err = json.NewDecoder(gz).Decode(m); err != nil {
+		return nil, err
+	}
+	m.countVocabulary()
+	return m, nil
+}
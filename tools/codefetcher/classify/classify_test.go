@@ -0,0 +1,104 @@
+package classify
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	testPython = []string{
+		"def main():\n    print('hello')\n\nif __name__ == '__main__':\n    main()\n",
+		"import os\n\ndef walk(path):\n    for name in os.listdir(path):\n        print(name)\n",
+		"class Point:\n    def __init__(self, x, y):\n        self.x = x\n        self.y = y\n",
+	}
+	testGo = []string{
+		"package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n",
+		"package walk\n\nimport \"os\"\n\nfunc Walk(path string) error {\n\t_, err := os.ReadDir(path)\n\treturn err\n}\n",
+		"package point\n\ntype Point struct {\n\tX, Y int\n}\n\nfunc (p Point) Add(q Point) Point {\n\treturn Point{p.X + q.X, p.Y + q.Y}\n}\n",
+	}
+)
+
+func newTestModel() *Model {
+	model := NewModel(0, 0)
+	for _, code := range testPython {
+		model.Add("Python", []byte(code))
+	}
+	for _, code := range testGo {
+		model.Add("Go", []byte(code))
+	}
+	return model
+}
+
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize([]byte("#include <stdio.h>\nint x = 42; // ü\n"), 0)
+	expected := []string{"#include", "<", "stdio", ".", "h", ">", "int", "x", "=", "0", ";", "/", "/"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Fatalf("Expected tokens %v, got %v", expected, tokens)
+	}
+	if tokens = Tokenize([]byte("a b c"), 2); len(tokens) != 2 {
+		t.Fatalf("Expected 2 tokens, got %v", tokens)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	model := newTestModel()
+
+	tests := map[string]string{
+		"def parse(line):\n    return line.split(',')\n":                 "Python",
+		"func parse(line string) []string {\n\treturn nil\n}\n":          "Go",
+		"import sys\n\nfor line in sys.stdin:\n    print(line)\n":        "Python",
+		"package parse\n\nimport \"strings\"\n\nvar x = strings.Split\n": "Go",
+	}
+	for code, expected := range tests {
+		prediction, err := model.Predict([]byte(code))
+		if err != nil {
+			t.Fatalf("Error predicting: %v", err)
+		}
+		if prediction.Language != expected {
+			t.Errorf("Expected %s for %q, got %+v", expected, code, prediction)
+		}
+		if sum := prediction.Scores["Python"] + prediction.Scores["Go"]; sum < 0.999 || sum > 1.001 {
+			t.Errorf("Expected scores to sum up to 1, got %f", sum)
+		}
+	}
+
+	if _, err := NewModel(0, 0).Predict([]byte("x")); err != ErrorEmptyModel {
+		t.Fatalf("Expected error %s, got %v", ErrorEmptyModel, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	model := newTestModel()
+	total := model.Totals["Go"]
+	model.Prune(2)
+	if model.Features["Go"]["Walk"] != 0 || model.Features["Go"]["package"] != 3 {
+		t.Fatalf("Expected rare n-grams to be pruned, got %d and %d", model.Features["Go"]["Walk"], model.Features["Go"]["package"])
+	}
+	if model.Totals["Go"] >= total {
+		t.Fatalf("Expected total to shrink from %d, got %d", total, model.Totals["Go"])
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	model := newTestModel()
+	var buffer bytes.Buffer
+	if err := model.Save(&buffer); err != nil {
+		t.Fatalf("Error saving model: %v", err)
+	}
+	loaded, err := Load(&buffer)
+	if err != nil {
+		t.Fatalf("Error loading model: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, model) {
+		t.Fatalf("Expected loaded model to equal the saved model")
+	}
+
+	code := []byte(strings.Join(testGo, "\n"))
+	expected, _ := model.Predict(code)
+	prediction, _ := loaded.Predict(code)
+	if !reflect.DeepEqual(prediction, expected) {
+		t.Fatalf("Expected prediction %+v, got %+v", expected, prediction)
+	}
+}
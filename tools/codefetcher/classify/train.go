@@ -0,0 +1,214 @@
+package classify
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"sort"
+)
+
+// FilterClassifierMismatch name of Filter, counted as skip reason
+const FilterClassifierMismatch = "classifier mismatch"
+
+// TrainOptions parameters of Train
+type TrainOptions struct {
+	Filter          codefetcher.CodefileFilter // code files used for training and evaluation, Splits is ignored
+	NGram           int                        // 0 for DefaultNGram
+	MaxTokens       int                        // tokens per file, 0 for all
+	MinCount        int                        // n-grams seen less often per language are pruned
+	EvaluationSplit string                     // held-out split, empty for codefetcher.SplitTest
+}
+
+// LanguageEvaluation evaluation of the held-out files of a language
+type LanguageEvaluation struct {
+	Files     int            `json:"files"`
+	Predicted map[string]int `json:"predicted"` // row of the confusion matrix, files by predicted language
+	Precision float64        `json:"precision"`
+	Recall    float64        `json:"recall"`
+}
+
+// Evaluation accuracy of a model on held-out files along with a confusion matrix
+type Evaluation struct {
+	Split     string                         `json:"split"`
+	Files     int                            `json:"files"`
+	Correct   int                            `json:"correct"`
+	Accuracy  float64                        `json:"accuracy"`
+	Languages map[string]*LanguageEvaluation `json:"languages"` // by labeled language
+}
+
+func newEvaluation(split string) Evaluation {
+	return Evaluation{Split: split, Languages: make(map[string]*LanguageEvaluation)}
+}
+
+func (e *Evaluation) add(language, predicted string) {
+	l, ok := e.Languages[language]
+	if !ok {
+		l = &LanguageEvaluation{Predicted: make(map[string]int)}
+		e.Languages[language] = l
+	}
+	l.Files++
+	l.Predicted[predicted]++
+	e.Files++
+	if language == predicted {
+		e.Correct++
+	}
+}
+
+// finish computes accuracy, precision and recall from the confusion matrix
+func (e *Evaluation) finish() {
+	if e.Files > 0 {
+		e.Accuracy = float64(e.Correct) / float64(e.Files)
+	}
+	predicted := make(map[string]int)
+	for _, l := range e.Languages {
+		for language, count := range l.Predicted {
+			predicted[language] += count
+		}
+	}
+	for language, l := range e.Languages {
+		l.Recall = float64(l.Predicted[language]) / float64(l.Files)
+		if predicted[language] > 0 {
+			l.Precision = float64(l.Predicted[language]) / float64(predicted[language])
+		}
+	}
+}
+
+// Labels returns the labeled and predicted languages of the confusion matrix, sorted by name
+func (e Evaluation) Labels() []string {
+	labels := make(map[string]bool)
+	for language, l := range e.Languages {
+		labels[language] = true
+		for predicted := range l.Predicted {
+			labels[predicted] = true
+		}
+	}
+	var languages []string
+	for language := range labels {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// codefileSplit returns the split of c, files stored before splits were assigned are assigned by config
+func codefileSplit(c codefetcher.Codefile, config codefetcher.SplitConfig) string {
+	if len(c.Split) > 0 {
+		return c.Split
+	}
+	return config.Assign(c.URL)
+}
+
+// Train trains a model on the train split of s and evaluates it on the held-out split. The
+// splits are assigned by repository, so the evaluation never sees a repository used for training.
+func Train(ctx context.Context, s codefetcher.Storage, options TrainOptions) (*Model, Evaluation, error) {
+	if len(options.EvaluationSplit) == 0 {
+		options.EvaluationSplit = codefetcher.SplitTest
+	}
+	options.Filter.Splits = nil
+	options.Filter.SkipContent = false
+
+	config, err := s.GetSplitConfig(ctx)
+	if err != nil {
+		return nil, Evaluation{}, err
+	}
+
+	model := NewModel(options.NGram, options.MaxTokens)
+	err = s.IterateCodefiles(ctx, options.Filter, func(c codefetcher.Codefile) error {
+		if codefileSplit(c, config) == codefetcher.SplitTrain {
+			model.Add(c.Language, c.Content)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, Evaluation{}, err
+	}
+	if len(model.Documents) == 0 {
+		return nil, Evaluation{}, ErrorEmptyModel
+	}
+	if options.MinCount > 1 {
+		model.Prune(options.MinCount)
+	}
+
+	evaluation, err := Evaluate(ctx, s, model, options.Filter, options.EvaluationSplit)
+	return model, evaluation, err
+}
+
+// Evaluate predicts the language of the code files of split in s and compares them with their labels
+func Evaluate(ctx context.Context, s codefetcher.Storage, model *Model, filter codefetcher.CodefileFilter, split string) (Evaluation, error) {
+	evaluation := newEvaluation(split)
+	config, err := s.GetSplitConfig(ctx)
+	if err != nil {
+		return evaluation, err
+	}
+
+	filter.Splits = nil
+	filter.SkipContent = false
+	err = s.IterateCodefiles(ctx, filter, func(c codefetcher.Codefile) error {
+		if codefileSplit(c, config) != split {
+			return ctx.Err()
+		}
+		prediction, err := model.Predict(c.Content)
+		if err != nil {
+			return err
+		}
+		evaluation.add(c.Language, prediction.Language)
+		return ctx.Err()
+	})
+	evaluation.finish()
+	return evaluation, err
+}
+
+// Mislabel a stored code file predicted as another language
+type Mislabel struct {
+	ID         int64   `json:"id"`
+	URL        string  `json:"url"`
+	Language   string  `json:"language"`
+	Predicted  string  `json:"predicted"`
+	Confidence float64 `json:"confidence"`
+}
+
+// FindMislabeled returns the code files of s the model predicts as another language with at
+// least minConfidence. Files of languages unknown to the model are skipped.
+func FindMislabeled(ctx context.Context, s codefetcher.Storage, model *Model, filter codefetcher.CodefileFilter, minConfidence float64) ([]Mislabel, error) {
+	var mislabels []Mislabel
+	filter.SkipContent = false
+	err := s.IterateCodefiles(ctx, filter, func(c codefetcher.Codefile) error {
+		if _, ok := model.Documents[c.Language]; !ok {
+			return ctx.Err()
+		}
+		prediction, err := model.Predict(c.Content)
+		if err != nil {
+			return err
+		}
+		if prediction.Language != c.Language && prediction.Confidence >= minConfidence {
+			mislabels = append(mislabels, Mislabel{
+				ID:         c.ID,
+				URL:        c.URL,
+				Language:   c.Language,
+				Predicted:  prediction.Language,
+				Confidence: prediction.Confidence,
+			})
+		}
+		return ctx.Err()
+	})
+	return mislabels, err
+}
+
+// Filter rejects code files the model predicts as another language with at least MinConfidence,
+// an ingest-time check for sources without reliable labels
+type Filter struct {
+	Model         *Model
+	MinConfidence float64
+}
+
+func (f Filter) Name() string { return FilterClassifierMismatch }
+
+func (f Filter) Accept(language codefetcher.Language, _ string, code []byte) bool {
+	if _, ok := f.Model.Documents[language.String()]; !ok {
+		return true
+	}
+	prediction, err := f.Model.Predict(code)
+	if err != nil {
+		return true
+	}
+	return prediction.Language == language.String() || prediction.Confidence < f.MinConfidence
+}
@@ -0,0 +1,136 @@
+package classify
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+const testTimeout = 10 * time.Second
+
+// createTestDatabase stores the test samples of both languages across many repositories, so
+// every split has files of both languages
+func createTestDatabase(t *testing.T) codefetcher.Storage {
+	db, err := sql.Open("sqlite", t.TempDir()+"/test.db")
+	if err != nil {
+		t.Fatalf("Error creating database: %v", err)
+	}
+	s := codefetcher.Storage{DB: db}
+	if err = s.Init(context.Background()); err != nil {
+		t.Fatalf("Error initializing database: %v", err)
+	}
+	if err = s.SetSplitConfig(context.Background(), codefetcher.SplitConfig{Train: 0.5, Test: 0.5}); err != nil {
+		t.Fatalf("Error setting split config: %v", err)
+	}
+
+	python, _ := codefetcher.ParseLanguage("python")
+	golang, _ := codefetcher.ParseLanguage("go")
+	for i := 0; i < 20; i++ {
+		for j, code := range testPython {
+			url := fmt.Sprintf("https://github.com/user%d/scripts/blob/0123/%d.py", i, j)
+			if err = s.StoreCodefile(context.Background(), python, url, []byte(fmt.Sprintf("%s# %d\n", code, i)), ""); err != nil {
+				t.Fatalf("Error storing code file: %v", err)
+			}
+		}
+		for j, code := range testGo {
+			url := fmt.Sprintf("https://github.com/user%d/tools/blob/0123/%d.go", i, j)
+			if err = s.StoreCodefile(context.Background(), golang, url, []byte(fmt.Sprintf("%s// %d\n", code, i)), ""); err != nil {
+				t.Fatalf("Error storing code file: %v", err)
+			}
+		}
+	}
+	return s
+}
+
+func TestTrain(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTestDatabase(t)
+	defer s.DB.Close()
+
+	model, evaluation, err := Train(ctx, s, TrainOptions{MinCount: DefaultMinCount})
+	if err != nil {
+		t.Fatalf("Error training: %v", err)
+	}
+	if len(model.Languages()) != 2 {
+		t.Fatalf("Expected 2 languages, got %v", model.Languages())
+	}
+	if evaluation.Split != codefetcher.SplitTest || evaluation.Files == 0 || evaluation.Accuracy != 1 {
+		t.Fatalf("Unexpected evaluation %+v", evaluation)
+	}
+	if l := evaluation.Languages["Go"]; l == nil || l.Precision != 1 || l.Recall != 1 {
+		t.Fatalf("Unexpected Go evaluation %+v", l)
+	}
+
+	trained := 0
+	for _, count := range model.Documents {
+		trained += count
+	}
+	if trained+evaluation.Files != 120 {
+		t.Fatalf("Expected train and test split to cover 120 files, got %d and %d", trained, evaluation.Files)
+	}
+
+	validation, err := Evaluate(ctx, s, model, codefetcher.CodefileFilter{}, codefetcher.SplitValidation)
+	if err != nil {
+		t.Fatalf("Error evaluating: %v", err)
+	}
+	if validation.Files != 0 {
+		t.Fatalf("Expected empty validation split, got %d files", validation.Files)
+	}
+
+	golang, _ := codefetcher.ParseLanguage("go")
+	if _, _, err = Train(ctx, s, TrainOptions{Filter: codefetcher.CodefileFilter{Languages: []codefetcher.Language{golang}}, EvaluationSplit: codefetcher.SplitValidation}); err != nil {
+		t.Fatalf("Error training a single language: %v", err)
+	}
+}
+
+func TestFindMislabeled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTestDatabase(t)
+	defer s.DB.Close()
+
+	python, _ := codefetcher.ParseLanguage("python")
+	mislabeled := []byte(testGo[0] + testGo[1])
+	if err := s.StoreCodefile(ctx, python, "https://github.com/carol/tools/blob/0123/main.py", mislabeled, ""); err != nil {
+		t.Fatalf("Error storing code file: %v", err)
+	}
+
+	model := newTestModel()
+	mislabels, err := FindMislabeled(ctx, s, model, codefetcher.CodefileFilter{}, 0.99)
+	if err != nil {
+		t.Fatalf("Error finding mislabeled files: %v", err)
+	}
+	if len(mislabels) != 1 || mislabels[0].Predicted != "Go" || mislabels[0].Language != "Python" {
+		t.Fatalf("Expected 1 mislabeled file, got %+v", mislabels)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	python, _ := codefetcher.ParseLanguage("python")
+	golang, _ := codefetcher.ParseLanguage("go")
+	kotlin, _ := codefetcher.ParseLanguage("kotlin")
+	filter := Filter{Model: newTestModel(), MinConfidence: 0.99}
+
+	if filter.Accept(python, "main.py", []byte(testGo[0])) {
+		t.Fatalf("Expected Go code labeled as Python to be rejected")
+	}
+	if !filter.Accept(golang, "main.go", []byte(testGo[0])) {
+		t.Fatalf("Expected Go code to be accepted")
+	}
+	if !filter.Accept(kotlin, "main.kt", []byte(testGo[0])) {
+		t.Fatalf("Expected languages unknown to the model to be accepted")
+	}
+
+	var filters codefetcher.Filters = []codefetcher.Filter{filter}
+	if name, ok := filters.Check(python, "main.py", []byte(testGo[0])); ok || name != FilterClassifierMismatch {
+		t.Fatalf("Expected %q, got %q", FilterClassifierMismatch, name)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	splitArg      *string  = flag.String("split", "", "export, stats, sample: Only files of these dataset splits (train, validation, test)")
+	trainRatioArg *float64 = flag.Float64("train-ratio", codefetcher.DefaultSplitConfig.Train, "assign-splits: Ratio of repositories in the train split")
+	validRatioArg *float64 = flag.Float64("validation-ratio", codefetcher.DefaultSplitConfig.Validation, "assign-splits: Ratio of repositories in the validation split")
+	testRatioArg  *float64 = flag.Float64("test-ratio", codefetcher.DefaultSplitConfig.Test, "assign-splits: Ratio of repositories in the test split")
+	seedArg       *int64   = flag.Int64("seed", 0, "assign-splits, sample: Random seed")
+)
+
+func init() {
+	registerCommand("assign-splits", "Assign code files to train/validation/test splits by repository (--train-ratio, --seed)", runAssignSplits)
+}
+
+// parseSplits parses a comma separated list of dataset splits, an empty list selects all splits
+func parseSplits(arg string) ([]string, error) {
+	var splits []string
+	for _, split := range strings.Split(arg, ",") {
+		split = strings.TrimSpace(split)
+		if len(split) == 0 {
+			continue
+		}
+		valid := false
+		for _, s := range codefetcher.Splits {
+			valid = valid || s == split
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown split %s", split)
+		}
+		splits = append(splits, split)
+	}
+	return splits, nil
+}
+
+func runAssignSplits(ctx context.Context) error {
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	config, err := s.GetSplitConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// a changed config moves repositories between splits, so every file is reassigned
+	changed := false
+	if flag.CommandLine.Changed("train-ratio") {
+		config.Train = *trainRatioArg
+		changed = true
+	}
+	if flag.CommandLine.Changed("validation-ratio") {
+		config.Validation = *validRatioArg
+		changed = true
+	}
+	if flag.CommandLine.Changed("test-ratio") {
+		config.Test = *testRatioArg
+		changed = true
+	}
+	if flag.CommandLine.Changed("seed") {
+		config.Seed = *seedArg
+		changed = true
+	}
+	if changed {
+		if err = s.SetSplitConfig(ctx, config); err != nil {
+			log.Errorf("Invalid split config: %s", err.Error())
+			usage(1)
+		}
+		log.Infof("Split config set to train=%g validation=%g test=%g seed=%d", config.Train, config.Validation, config.Test, config.Seed)
+	}
+
+	assigned, err := s.AssignSplits(ctx, changed)
+	if err != nil {
+		return err
+	}
+	counts, err := s.CountSplits(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(struct {
+			Config   codefetcher.SplitConfig `json:"config"`
+			Assigned map[string]int          `json:"assigned"`
+			Splits   map[string]int          `json:"splits"`
+		}{config, assigned, counts})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "split\tfiles\tassigned\t")
+	for _, split := range codefetcher.Splits {
+		fmt.Fprintf(w, "%s\t%d\t%d\t\n", split, counts[split], assigned[split])
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+var (
+	blocklistArg *string = flag.String("blocklist", "", "fetch, import, purge, blocklist: Blocklist file with one owner:<name>, repo:<owner/name> or path:<glob> entry per line")
+	reasonArg    *string = flag.String("reason", "", "blocklist: Reason recorded for added entries, e.g. an opt-out request")
+	dryRunArg    *bool   = flag.Bool("dry-run", false, "purge: Only count the code files matching the blocklist")
+)
+
+func init() {
+	registerCommand("blocklist", "List the blocklist, or add/remove owner:, repo: or path: entries", runBlocklist)
+	registerCommand("purge", "Delete code files matching the blocklist and leave tombstones (--dry-run)", runPurge)
+}
+
+// readBlocklistFile reads --blocklist, the blocklist is empty if no file is given
+func readBlocklistFile() (codefetcher.Blocklist, error) {
+	if len(*blocklistArg) == 0 {
+		return nil, nil
+	}
+	f, err := os.Open(*blocklistArg)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return codefetcher.ReadBlocklist(f)
+}
+
+// loadBlocklist returns the blocklist table of s along with the entries of --blocklist
+func loadBlocklist(ctx context.Context, s codefetcher.BlocklistStore) (codefetcher.Blocklist, error) {
+	blocklist, err := readBlocklistFile()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.GetBlocklist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(stored, blocklist...), nil
+}
+
+func runBlocklist(ctx context.Context) error {
+	args := flag.Args()[1:]
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	var entries codefetcher.Blocklist
+	for _, arg := range args {
+		e, err := codefetcher.ParseBlocklistEntry(arg)
+		if err != nil {
+			log.Errorf("Invalid blocklist entry \"%s\"", arg)
+			usage(1)
+		}
+		e.Reason = *reasonArg
+		entries = append(entries, e)
+	}
+	file, err := readBlocklistFile()
+	if err != nil {
+		return err
+	}
+	entries = append(entries, file...)
+
+	if action != "list" && len(entries) == 0 {
+		log.Error("Missing blocklist entries")
+		usage(1)
+	}
+
+	backend, db, err := openBackend(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer db.Close()
+	s := backend.(codefetcher.BlocklistStore)
+
+	switch action {
+	case "add":
+		if err = s.AddBlocklist(ctx, entries); err != nil {
+			return err
+		}
+		log.Infof("Added %d blocklist entries, run purge to delete code files already stored", len(entries))
+		return nil
+	case "remove":
+		removed, err := s.RemoveBlocklist(ctx, entries)
+		if err != nil {
+			return err
+		}
+		log.Infof("Removed %d blocklist entries, purged code files stay tombstoned", removed)
+		return nil
+	case "list":
+	default:
+		log.Errorf("Unknown blocklist action \"%s\"", action)
+		usage(1)
+	}
+
+	blocklist, err := s.GetBlocklist(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		if blocklist == nil {
+			blocklist = codefetcher.Blocklist{}
+		}
+		return printJSON(blocklist)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "kind\tpattern\treason\t")
+	for _, e := range blocklist {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", e.Kind, e.Pattern, e.Reason)
+	}
+	w.Flush()
+	return nil
+}
+
+func runPurge(ctx context.Context) error {
+	backend, db, err := openBackend(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer db.Close()
+	s := backend.(codefetcher.BlocklistStore)
+
+	blocklist, err := loadBlocklist(ctx, s)
+	if err != nil {
+		return err
+	}
+	if len(blocklist) == 0 {
+		log.Error("Empty blocklist, add entries with the blocklist command or pass --blocklist")
+		usage(1)
+	}
+
+	report, err := s.Purge(ctx, blocklist, *dryRunArg)
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(report)
+	}
+
+	var languages []string
+	for language := range report.Languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "language\tpurged\t")
+	for _, language := range languages {
+		fmt.Fprintf(w, "%s\t%d\t\n", language, report.Languages[language])
+	}
+	w.Flush()
+
+	if *dryRunArg {
+		log.Infof("Dry run, %d code files would be purged", report.Purged)
+	} else {
+		log.Infof("Purged %d code files", report.Purged)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+var (
+	repairArg *bool = flag.Bool("repair", false, "check-blobs: delete orphaned blobs and rows with missing blobs")
+)
+
+func init() {
+	registerCommand("check-blobs", "Check the blob store for orphaned or missing blobs", runCheckBlobs)
+}
+
+func runCheckBlobs(ctx context.Context) error {
+	if len(*blobDirArg) == 0 && len(*s3EndpointArg) == 0 {
+		log.Error("Missing argument blob directory or S3 endpoint")
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	report, err := s.CheckBlobs(ctx, *repairArg)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range report.Orphaned {
+		log.Warnf("Orphaned blob: %s", key)
+	}
+	for _, key := range report.Missing {
+		log.Warnf("Missing blob: %s", key)
+	}
+	fmt.Printf("referenced=%d orphaned=%d missing=%d\n", report.Referenced, len(report.Orphaned), len(report.Missing))
+	if *repairArg && len(report.Orphaned)+len(report.Missing) > 0 {
+		log.Infof("Deleted %d orphaned blobs and %d rows with missing blobs", len(report.Orphaned), len(report.Missing))
+	}
+	return nil
+}
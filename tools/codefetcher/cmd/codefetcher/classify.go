@@ -0,0 +1,159 @@
+package main
+
+import (
+	"codefetcher/classify"
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"text/tabwriter"
+)
+
+var (
+	modelArg         *string  = flag.String("model", "", "classify, fetch, import: Language classifier model file, fetch and import skip files classified as another language")
+	ngramArg         *int     = flag.Int("ngram", classify.DefaultNGram, "classify: Maximum number of tokens per n-gram")
+	maxTokensArg     *int     = flag.Int("max-tokens", classify.DefaultMaxTokens, "classify: Number of leading tokens per file used by the classifier (0 = all)")
+	minCountArg      *int     = flag.Int("min-count", classify.DefaultMinCount, "classify: Prune n-grams seen less often per language")
+	evalSplitArg     *string  = flag.String("eval-split", codefetcher.SplitTest, "classify: Held-out split used for the evaluation")
+	minConfidenceArg *float64 = flag.Float64("min-confidence", 0.99, "classify, fetch, import: Minimum classifier confidence to report or skip a file as another language")
+)
+
+func init() {
+	registerCommand("classify", "Train a language classifier (train), evaluate it (evaluate) or find mislabeled files (check) --model", runClassify)
+}
+
+// loadModel reads the classifier model given by --model
+func loadModel() (*classify.Model, error) {
+	f, err := os.Open(*modelArg)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return classify.Load(f)
+}
+
+func saveModel(model *classify.Model) error {
+	f, err := os.Create(*modelArg)
+	if err != nil {
+		return err
+	}
+	if err = model.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runClassify(ctx context.Context) error {
+	action := "train"
+	if flag.NArg() > 1 {
+		action = flag.Arg(1)
+	}
+	if len(*modelArg) == 0 {
+		log.Error("Missing argument model")
+		usage(1)
+	}
+
+	languages, err := parseLanguages(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+	filter := codefetcher.CodefileFilter{Languages: languages, SkipGenerated: *skipGeneratedArg}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	var evaluation classify.Evaluation
+	switch action {
+	case "train":
+		var model *classify.Model
+		model, evaluation, err = classify.Train(ctx, s, classify.TrainOptions{
+			Filter:          filter,
+			NGram:           *ngramArg,
+			MaxTokens:       *maxTokensArg,
+			MinCount:        *minCountArg,
+			EvaluationSplit: *evalSplitArg,
+		})
+		if err != nil {
+			return err
+		}
+		if err = saveModel(model); err != nil {
+			return err
+		}
+		log.Infof("Saved model of %d languages to %s", len(model.Documents), *modelArg)
+	case "evaluate":
+		model, err := loadModel()
+		if err != nil {
+			return err
+		}
+		if evaluation, err = classify.Evaluate(ctx, s, model, filter, *evalSplitArg); err != nil {
+			return err
+		}
+	case "check":
+		model, err := loadModel()
+		if err != nil {
+			return err
+		}
+		return printMislabeled(ctx, s, model, filter)
+	default:
+		log.Errorf("Unknown classify action \"%s\"", action)
+		usage(1)
+	}
+
+	log.Infof("Accuracy on %d files of the %s split: %.4f", evaluation.Files, evaluation.Split, evaluation.Accuracy)
+	if *formatArg == "json" {
+		return printJSON(evaluation)
+	}
+
+	// confusion matrix, rows by labeled language and columns by predicted language
+	labels := evaluation.Labels()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "language\tfiles\t")
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s\t", label)
+	}
+	fmt.Fprintln(w, "precision\trecall\t")
+	for _, label := range labels {
+		l, ok := evaluation.Languages[label]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t", label, l.Files)
+		for _, predicted := range labels {
+			fmt.Fprintf(w, "%d\t", l.Predicted[predicted])
+		}
+		fmt.Fprintf(w, "%.3f\t%.3f\t\n", l.Precision, l.Recall)
+	}
+	w.Flush()
+	return nil
+}
+
+func printMislabeled(ctx context.Context, s codefetcher.Storage, model *classify.Model, filter codefetcher.CodefileFilter) error {
+	mislabels, err := classify.FindMislabeled(ctx, s, model, filter, *minConfidenceArg)
+	if err != nil {
+		return err
+	}
+	log.Infof("Found %d code files classified as another language", len(mislabels))
+
+	if *formatArg == "json" {
+		if mislabels == nil {
+			mislabels = []classify.Mislabel{}
+		}
+		return printJSON(mislabels)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "id\tlanguage\tpredicted\tconfidence\turl\t")
+	for _, m := range mislabels {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.4f\t%s\t\n", m.ID, m.Language, m.Predicted, m.Confidence, m.URL)
+	}
+	w.Flush()
+	return nil
+}
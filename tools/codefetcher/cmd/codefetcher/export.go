@@ -0,0 +1,120 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	exportFormatArg *string = flag.String("export-format", "jsonl", "export, sample: Export format (jsonl, parquet, tree)")
+	outputArg       *string = flag.String("output", "-", "export, sample: Output file, \"-\" for stdout (tree: output directory, sample: *.db writes a database)")
+	gzipArg         *bool   = flag.Bool("gzip", false, "export, sample: Gzip compress jsonl output, default for output files ending in .gz")
+	minSizeArg      *int    = flag.Int("min-size", 0, "export, sample: Minimum file size in bytes")
+	maxSizeArg      *int    = flag.Int("max-size", 0, "export, sample: Maximum file size in bytes (0 = unlimited)")
+)
+
+func init() {
+	registerCommand("export", "Export code files to JSONL, Parquet or a file tree (--export-format, --output)", runExport)
+}
+
+// createOutput opens the output file, "-" writes to stdout
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// openExporter creates the exporter selected by --export-format writing to --output,
+// the returned output is nil for tree exports
+func openExporter() (codefetcher.Exporter, io.WriteCloser, error) {
+	var output io.WriteCloser
+	var exporter codefetcher.Exporter
+	var err error
+	switch *exportFormatArg {
+	case "jsonl":
+		if output, err = createOutput(*outputArg); err != nil {
+			return nil, nil, err
+		}
+		exporter = codefetcher.NewJSONLExporter(output, *gzipArg || strings.HasSuffix(*outputArg, ".gz"))
+	case "parquet":
+		if *outputArg == "-" {
+			log.Error("Parquet export requires an output file")
+			usage(1)
+		}
+		if output, err = createOutput(*outputArg); err != nil {
+			return nil, nil, err
+		}
+		exporter = codefetcher.NewParquetExporter(output)
+	case "tree":
+		if *outputArg == "-" {
+			log.Error("Tree export requires an output directory")
+			usage(1)
+		}
+		if exporter, err = codefetcher.NewTreeExporter(*outputArg); err != nil {
+			return nil, nil, err
+		}
+	default:
+		log.Errorf("Invalid argument export format \"%s\"", *exportFormatArg)
+		usage(1)
+	}
+	return exporter, output, nil
+}
+
+func runExport(ctx context.Context) error {
+	languages, err := parseLanguages(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+
+	splits, err := parseSplits(*splitArg)
+	if err != nil {
+		log.Error("Invalid argument split")
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	exporter, output, err := openExporter()
+	if err != nil {
+		return err
+	}
+	if output != nil {
+		defer output.Close()
+	}
+
+	count, err := s.Export(ctx, codefetcher.CodefileFilter{
+		Languages:     languages,
+		MinSize:       *minSizeArg,
+		MaxSize:       *maxSizeArg,
+		Splits:        splits,
+		SkipGenerated: *skipGeneratedArg,
+	}, exporter)
+	if err != nil {
+		exporter.Close()
+		return err
+	}
+	if err = exporter.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("Exported %d code files to %s", count, *outputArg)
+	return nil
+}
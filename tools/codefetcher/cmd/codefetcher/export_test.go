@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"codefetcher/codefetcher"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testMainEnv makes the test binary run main with the remaining arguments, see runCommand
+const testMainEnv = "CODEFETCHER_TEST_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testMainEnv) == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs the binary with args, returns its stdout and stderr
+func runCommand(t *testing.T, args ...string) ([]byte, []byte) {
+	stdout, stderr, err := execCommand(args...)
+	if err != nil {
+		t.Fatalf("Error running %v: %v\n%s", args, err, stderr)
+	}
+	return stdout, stderr
+}
+
+// execCommand runs the binary with args, the error holds the exit status
+func execCommand(args ...string) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), testMainEnv+"=1")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+func TestExportStdout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "codes.db")
+	createTestDatabase(t, ctx, path, 3)
+	python, _ := codefetcher.ParseLanguage("python")
+
+	// logs at debug level must not end up in the exported stream
+	stdout, stderr := runCommand(t, "export", "--database", path, "--log-level", "debug", "--output", "-")
+	if len(stderr) == 0 {
+		t.Fatalf("Expected log output on stderr")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(stdout))
+	var records int
+	for {
+		var record codefetcher.ExportRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Error decoding record %d: %v\n%s", records+1, err, stdout)
+		}
+		if record.Language != python.String() || len(record.Content) == 0 {
+			t.Fatalf("Unexpected record %+v", record)
+		}
+		records++
+	}
+	if records != 3 {
+		t.Fatalf("Expected 3 records, got %d", records)
+	}
+}
+
+// createTestDatabase creates an SQLite database at path with count Python files
+func createTestDatabase(t *testing.T, ctx context.Context, path string, count int) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	s := codefetcher.Storage{DB: db}
+	if err = s.Init(ctx); err != nil {
+		t.Fatalf("Error initializing database: %v", err)
+	}
+	python, _ := codefetcher.ParseLanguage("python")
+	for i := 0; i < count; i++ {
+		content := []byte(fmt.Sprintf("print(%d)\n", i))
+		url := fmt.Sprintf("https://github.com/alice/tools/blob/main/%d.py", i)
+		if err = s.StoreCodefile(ctx, python, url, content, codefetcher.GitBlobHash(content)); err != nil {
+			t.Fatalf("Error storing codefile: %v", err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"time"
+)
+
+var (
+	githubUserArg     *string = flag.String("github-user", "", "Github username")
+	githubTokenArg    *string = flag.String("github-token", "", "Github access token")
+	queryArg          *string = flag.StringP("query", "q", "", "Search terms and qualifiers (path, filename, extension, repo, org, size), e.g. \"http path:src size:<10000\", seed queries are generated if empty")
+	languageArg       *string = flag.StringP("language", "l", "", "Programming language, see the languages command for the registered languages and their aliases")
+	maxCodeSizeArg    *int    = flag.Int("max-code-size", 0, "Maximum total code size per language in bytes (0 = unlimited)")
+	requestTimeoutArg *int    = flag.IntP("timeout", "t", 2000, "Timeout between requests in milliseconds")
+	maxRepoFilesArg   *int    = flag.Int("max-files-per-repo", 0, "Maximum number of code files per repository (0 = unlimited)")
+	maxOwnerBytesArg  *int    = flag.Int("max-bytes-per-owner", 0, "Maximum total code size per repository owner in bytes (0 = unlimited)")
+)
+
+func init() {
+	registerCommand("fetch", "Fetch code files from github.com (default)", runFetch)
+}
+
+func runFetch(ctx context.Context) error {
+	if len(*githubUserArg) == 0 {
+		log.Error("Missing argument github username")
+		usage(1)
+	}
+
+	if len(*githubTokenArg) == 0 {
+		log.Error("Missing argument github token")
+		usage(1)
+	}
+
+	var language codefetcher.Language
+	if len(*languageArg) == 0 {
+		log.Error("Missing argument language")
+		usage(1)
+	} else {
+		var err error
+		language, err = codefetcher.ParseLanguage(*languageArg)
+		if err != nil {
+			log.Error("Invalid argument language")
+			usage(1)
+		}
+	}
+
+	// without query, seed queries are generated once the database is open
+	if _, err := codefetcher.ParseLanguageSearchQuery(language, *queryArg); err != nil && len(*queryArg) > 0 {
+		log.Errorf("Invalid argument query: %s", err.Error())
+		usage(1)
+	}
+
+	var requestTimeout time.Duration = 0
+	if *requestTimeoutArg > 0 {
+		requestTimeout = time.Duration(*requestTimeoutArg) * time.Millisecond
+	}
+
+	filters, err := qualityFilters()
+	if err != nil {
+		log.Errorf("Invalid filter arguments: %s", err.Error())
+		usage(1)
+	}
+	paths, err := pathRules()
+	if err != nil {
+		log.Errorf("Invalid path arguments: %s", err.Error())
+		usage(1)
+	}
+
+	s, db, err := openBackend(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer db.Close()
+
+	log.Infof("Connected to database %s", *databaseArg)
+
+	queries := []string{*queryArg}
+	if len(*queryArg) == 0 {
+		seeds, err := seedQueries(ctx, s, language)
+		if err != nil {
+			return err
+		}
+		queries = queries[:0]
+		for _, seed := range seeds {
+			queries = append(queries, seed.Query)
+		}
+		log.Infof("Fetching code from github.com for language %s with %d seed queries", language.String(), len(queries))
+	} else {
+		log.Infof("Fetching code from github.com for language %s with query \"%s\"", language.String(), *queryArg)
+	}
+
+	run, err := startRun(ctx, s.(codefetcher.RunRecorder), codefetcher.SourceGithub, []codefetcher.Language{language}, queries)
+	if err != nil {
+		return err
+	}
+	// the split config is read once instead of per stored code file
+	switch storage := s.(type) {
+	case codefetcher.Storage:
+		config, err := storage.GetSplitConfig(ctx)
+		if err != nil {
+			return err
+		}
+		storage.RunID, storage.SplitConfig = run.ID, &config
+		s = storage
+	case codefetcher.PostgresStorage:
+		config, err := storage.GetSplitConfig(ctx)
+		if err != nil {
+			return err
+		}
+		storage.RunID, storage.SplitConfig = run.ID, &config
+		s = storage
+	}
+
+	blocklist, err := loadBlocklist(ctx, s.(codefetcher.BlocklistStore))
+	if err != nil {
+		return err
+	}
+
+	fetcher := codefetcher.NewGithubFetcher(*githubUserArg, *githubTokenArg, s, requestTimeout)
+	fetcher.Blocklist = blocklist
+	fetcher.Filters = filters
+	fetcher.Paths = paths
+	fetcher.VerifyLanguage = *verifyLanguageArg
+	fetcher.Limits = codefetcher.FetchLimits{
+		MaxFilesPerRepository: *maxRepoFilesArg,
+		MaxBytesPerOwner:      *maxOwnerBytesArg,
+	}
+	summary, err := fetcher.FetchQueries(ctx, language, queries, *maxCodeSizeArg)
+	if summaryErr := finishRun(ctx, s.(codefetcher.RunRecorder), run, summary, err); err == nil {
+		err = summaryErr
+	}
+	return err
+}
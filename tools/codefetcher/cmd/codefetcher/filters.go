@@ -0,0 +1,78 @@
+package main
+
+import (
+	"codefetcher/classify"
+	"codefetcher/codefetcher"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"os"
+)
+
+var (
+	minLinesArg          *int      = flag.Int("min-lines", codefetcher.DefaultQualityThresholds.MinLines, "fetch, import: Minimum number of lines per code file (0 = unlimited)")
+	maxLinesArg          *int      = flag.Int("max-lines", codefetcher.DefaultQualityThresholds.MaxLines, "fetch, import: Maximum number of lines per code file (0 = unlimited)")
+	maxLineLengthArg     *int      = flag.Int("max-line-length", codefetcher.DefaultQualityThresholds.MaxLineLength, "fetch, import: Maximum line length in characters (0 = unlimited)")
+	maxMeanLineLengthArg *float64  = flag.Float64("max-mean-line-length", codefetcher.DefaultQualityThresholds.MaxMeanLineLength, "fetch, import: Maximum mean line length in characters (0 = unlimited)")
+	minAlphanumericArg   *float64  = flag.Float64("min-alphanumeric-fraction", codefetcher.DefaultQualityThresholds.MinAlphanumericFraction, "fetch, import: Minimum fraction of letters and digits (0 = unlimited)")
+	maxNonASCIIArg       *float64  = flag.Float64("max-non-ascii-ratio", codefetcher.DefaultQualityThresholds.MaxNonASCIIRatio, "fetch, import: Maximum fraction of non-ASCII characters (0 = unlimited)")
+	includePathArg       *[]string = flag.StringSlice("include-path", nil, "fetch, import: Only store files with a path matching one of these globs, e.g. src/**")
+	excludePathArg       *[]string = flag.StringSlice("exclude-path", nil, "fetch, import: Skip files with a path matching one of these globs, e.g. **/test/**")
+	noDefaultExcludesArg *bool     = flag.Bool("no-default-excludes", false, "fetch, import: Don't skip vendored and third-party paths like **/vendor/** and **/node_modules/**")
+	verifyLanguageArg    *bool     = flag.Bool("verify-language", true, "fetch, import: Check the language by shebangs, modelines and keywords, correct it if the file extension matches the detected language or skip the file")
+	qualityConfigArg     *string   = flag.String("quality-config", "", "fetch, import: JSON file with filter thresholds per language, applied on top of the threshold flags")
+)
+
+// qualityFilters returns the content quality filters configured by the threshold flags and
+// --quality-config, along with the generated code filter if --generated is drop and the
+// classifier filter if --model is given
+func qualityFilters() (codefetcher.Filters, error) {
+	var filters codefetcher.Filters
+	switch *generatedArg {
+	case generatedTag:
+	case generatedDrop:
+		filters = append(filters, codefetcher.GeneratedFilter{})
+	default:
+		return nil, fmt.Errorf("invalid argument generated %s", *generatedArg)
+	}
+
+	config := codefetcher.QualityConfig{Default: codefetcher.QualityThresholds{
+		MinLines:                *minLinesArg,
+		MaxLines:                *maxLinesArg,
+		MaxLineLength:           *maxLineLengthArg,
+		MaxMeanLineLength:       *maxMeanLineLengthArg,
+		MinAlphanumericFraction: *minAlphanumericArg,
+		MaxNonASCIIRatio:        *maxNonASCIIArg,
+	}}
+	if len(*qualityConfigArg) > 0 {
+		f, err := os.Open(*qualityConfigArg)
+		if err != nil {
+			return nil, err
+		}
+		config, err = codefetcher.ReadQualityConfig(f, config.Default)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	filters = append(filters, config.Filters()...)
+
+	// the classifier is the most expensive filter, so it runs last
+	if len(*modelArg) > 0 {
+		model, err := loadModel()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, classify.Filter{Model: model, MinConfidence: *minConfidenceArg})
+	}
+	return filters, nil
+}
+
+// pathRules returns the path rules configured by --include-path, --exclude-path and --no-default-excludes
+func pathRules() (codefetcher.PathRules, error) {
+	rules := codefetcher.PathRules{
+		Include:    *includePathArg,
+		Exclude:    *excludePathArg,
+		NoDefaults: *noDefaultExcludesArg,
+	}
+	return rules, rules.Validate()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	generatedTag  = "tag"
+	generatedDrop = "drop"
+)
+
+var (
+	generatedArg     *string = flag.String("generated", generatedTag, "fetch, import: Tag generated code files or drop them (tag, drop)")
+	skipGeneratedArg *bool   = flag.Bool("skip-generated", false, "export, stats, sample: Skip code files tagged as generated")
+	retagArg         *bool   = flag.Bool("retag", false, "tag-generated: Check all code files again, not only untagged ones")
+)
+
+func init() {
+	registerCommand("tag-generated", "Tag stored code files detected as generated (--retag)", runTagGenerated)
+}
+
+func runTagGenerated(ctx context.Context) error {
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	report, err := s.TagGenerated(ctx, *retagArg)
+	if err != nil {
+		return err
+	}
+	log.Infof("Checked %d code files, %d generated", report.Checked, report.Generated)
+
+	if *formatArg == "json" {
+		return printJSON(report)
+	}
+
+	var names []string
+	for language := range report.Languages {
+		names = append(names, language)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "language\tgenerated\t")
+	for _, language := range names {
+		fmt.Fprintf(w, "%s\t%d\t\n", language, report.Languages[language])
+	}
+	w.Flush()
+	return nil
+}
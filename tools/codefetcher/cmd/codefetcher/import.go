@@ -0,0 +1,190 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"compress/gzip"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	sourceArg      *string            = flag.String("source", "", "import: Name of the imported dataset, recorded in every row")
+	fieldArg       *map[string]string = flag.StringToString("field", nil, "import: Dataset field names, e.g. content=text,lang=language,path=path,repo=repo_name")
+	languageMapArg *map[string]string = flag.StringToString("language-map", nil, "import: Dataset language names to codefetcher languages, e.g. C-Sharp=csharp")
+)
+
+func init() {
+	registerCommand("import", "Import code datasets from Parquet or JSONL files (--source)", runImport)
+}
+
+// openRecordReader opens a dataset file, the format is detected by the file extension
+func openRecordReader(path string) (codefetcher.ImportRecordReader, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".parquet"):
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		reader, err := codefetcher.NewParquetRecordReader(f, stat.Size())
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return reader, f.Close, nil
+	case strings.HasSuffix(path, ".jsonl.gz"), strings.HasSuffix(path, ".json.gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return codefetcher.NewJSONLRecordReader(gz), f.Close, nil
+	case strings.HasSuffix(path, ".jsonl"), strings.HasSuffix(path, ".json"):
+		return codefetcher.NewJSONLRecordReader(f), f.Close, nil
+	}
+
+	f.Close()
+	return nil, nil, fmt.Errorf("unknown dataset format of %s", path)
+}
+
+// importFiles imports the given dataset files into s, the reports are summed up in total
+func importFiles(ctx context.Context, s codefetcher.Storage, files []string, options codefetcher.ImportOptions, total *codefetcher.ImportReport) error {
+	for _, path := range files {
+		reader, closeReader, err := openRecordReader(path)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Importing %s", filepath.Base(path))
+		report, err := s.Import(ctx, reader, options)
+		closeReader()
+
+		total.MergeReport = total.MergeReport.Add(report.MergeReport)
+		total.Read += report.Read
+		for reason, count := range report.Skipped {
+			total.Skipped[reason] += count
+		}
+		for mismatch, count := range report.Mismatches {
+			total.Mismatches[mismatch] += count
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runImport(ctx context.Context) error {
+	files := flag.Args()[1:]
+	if len(files) == 0 {
+		log.Error("Missing dataset files")
+		usage(1)
+	}
+
+	if len(*sourceArg) == 0 {
+		log.Error("Missing argument source")
+		usage(1)
+	}
+
+	languages, err := parseLanguages(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+
+	filters, err := qualityFilters()
+	if err != nil {
+		log.Errorf("Invalid filter arguments: %s", err.Error())
+		usage(1)
+	}
+	paths, err := pathRules()
+	if err != nil {
+		log.Errorf("Invalid path arguments: %s", err.Error())
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	blocklist, err := loadBlocklist(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	run, err := startRun(ctx, s, *sourceArg, languages, nil)
+	if err != nil {
+		return err
+	}
+	s.RunID = run.ID
+
+	options := codefetcher.ImportOptions{
+		Source: *sourceArg,
+		Fields: codefetcher.ImportFieldMapping{
+			Content:  (*fieldArg)["content"],
+			Language: (*fieldArg)["lang"],
+			Path:     (*fieldArg)["path"],
+			Repo:     (*fieldArg)["repo"],
+		},
+		LanguageMapping: *languageMapArg,
+		Languages:       languages,
+		MaxTotalSize:    *maxCodeSizeArg,
+		Filters:         filters,
+		Paths:           paths,
+		Blocklist:       blocklist,
+		VerifyLanguage:  *verifyLanguageArg,
+		BatchSize:       *batchSizeArg,
+	}
+
+	total := codefetcher.ImportReport{Skipped: make(map[string]int), Mismatches: make(map[string]int)}
+	err = importFiles(ctx, s, files, options, &total)
+
+	summary := codefetcher.FetchSummary{Searched: total.Read, Skipped: total.Skipped, Mismatches: total.Mismatches}
+	for _, count := range total.Languages {
+		summary.Stored += count.Inserted
+		summary.Duplicates += count.Duplicates
+		if count.Tombstoned > 0 {
+			summary.Skipped[codefetcher.SkipReasonTombstoned] += count.Tombstoned
+		}
+	}
+	if summaryErr := finishRun(ctx, s, run, summary, err); err != nil || summaryErr != nil {
+		if err == nil {
+			err = summaryErr
+		}
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(total)
+	}
+
+	var names []string
+	for language := range total.Languages {
+		names = append(names, language)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "language\tinserted\tduplicates\ttombstoned\t")
+	for _, language := range names {
+		count := total.Languages[language]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", language, count.Inserted, count.Duplicates, count.Tombstoned)
+	}
+	w.Flush()
+	return nil
+}
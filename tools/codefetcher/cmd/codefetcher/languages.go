@@ -0,0 +1,62 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	flag "github.com/spf13/pflag"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	languageConfigArg *string = flag.String("language-config", "", "YAML or JSON file with language definitions, replacing built-in languages of the same name and adding others")
+)
+
+func init() {
+	registerCommand("languages", "List the registered languages along with their extensions and search qualifier", runLanguages)
+}
+
+// loadLanguages registers the built-in languages merged with the definitions of --language-config
+func loadLanguages() error {
+	if len(*languageConfigArg) == 0 {
+		return nil
+	}
+	f, err := os.Open(*languageConfigArg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	definitions, err := codefetcher.ReadLanguageDefinitions(f, codefetcher.DefaultLanguageDefinitions)
+	if err != nil {
+		return err
+	}
+	registry, err := codefetcher.NewLanguageRegistry(definitions)
+	if err != nil {
+		return err
+	}
+	codefetcher.SetLanguageRegistry(registry)
+	return nil
+}
+
+func runLanguages(ctx context.Context) error {
+	var definitions []codefetcher.LanguageDefinition
+	for _, language := range codefetcher.RegisteredLanguages() {
+		definitions = append(definitions, language.Definition())
+	}
+	if *formatArg == "json" {
+		return printJSON(definitions)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "language\taliases\textensions\tfilenames\tinterpreters\tquery\t")
+	for _, language := range codefetcher.RegisteredLanguages() {
+		d := language.Definition()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", d.Name, strings.Join(d.Aliases, ","), strings.Join(d.Extensions, ","),
+			strings.Join(d.Filenames, ","), strings.Join(d.Interpreters, ","), language.GithubLanguageQuery())
+	}
+	w.Flush()
+	return nil
+}
@@ -4,33 +4,63 @@ import (
 	"codefetcher/codefetcher"
 	"context"
 	"database/sql"
+	"encoding/json"
 	goflag "flag"
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	flag "github.com/spf13/pflag"
 	"os"
 	"os/signal"
-	"time"
+	"sort"
+	"strings"
 )
 
+// command a subcommand selected by the first positional argument
+type command struct {
+	description string
+	run         func(ctx context.Context) error
+}
+
+const defaultCommand = "fetch"
+
+// version of the binary, set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
 var (
-	helpArg           *bool   = flag.BoolP("help", "h", false, "Show help/usage")
-	logLevelArg       *string = flag.String("log-level", log.DebugLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
-	databaseArg       *string = flag.StringP("database", "d", "codes.db", "SQLite database path")
-	githubUserArg     *string = flag.String("github-user", "", "Github username")
-	githubTokenArg    *string = flag.String("github-token", "", "Github access token")
-	queryArg          *string = flag.StringP("query", "q", "", "Extra search terms for query")
-	languageArg       *string = flag.StringP("language", "l", "", fmt.Sprintf("Programming language (%s)", codefetcher.AvailableLanguages))
-	maxCodeSizeArg    *int    = flag.Int("max-code-size", 0, "Maximum total code size per language in bytes (0 = unlimited)")
-	requestTimeoutArg *int    = flag.IntP("timeout", "t", 2000, "Timeout between requests in milliseconds")
-
-	language       codefetcher.Language
-	requestTimeout time.Duration = 0
+	helpArg        *bool   = flag.BoolP("help", "h", false, "Show help/usage")
+	logLevelArg    *string = flag.String("log-level", log.DebugLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
+	databaseArg    *string = flag.StringP("database", "d", "codes.db", "SQLite database path or PostgreSQL URL (postgres://...)")
+	blobDirArg     *string = flag.String("blob-dir", "", "Store file contents in this content-addressed directory instead of the database")
+	formatArg      *string = flag.String("format", "text", "Output format (text, json)")
+	s3EndpointArg  *string = flag.String("s3-endpoint", "", "Store file contents in an S3-compatible bucket at this endpoint (host:port) instead of the database")
+	s3BucketArg    *string = flag.String("s3-bucket", "", "S3 bucket name")
+	s3PrefixArg    *string = flag.String("s3-prefix", "", "S3 object key prefix")
+	s3AccessKeyArg *string = flag.String("s3-access-key", "", "S3 access key")
+	s3SecretKeyArg *string = flag.String("s3-secret-key", "", "S3 secret key")
+	s3InsecureArg  *bool   = flag.Bool("s3-insecure", false, "Connect to the S3 endpoint via http instead of https")
+
+	commands = make(map[string]command)
 )
 
+func registerCommand(name, description string, run func(ctx context.Context) error) {
+	commands[name] = command{description: description, run: run}
+}
+
 func usage(exitCode int) {
-	fmt.Println("Usage: ./codefetcher [options]")
+	fmt.Println("Usage: ./codefetcher [command] [options]")
 	fmt.Println("  also see: https://docs.github.com/en/rest/search?apiVersion=2022-11-28")
+	fmt.Println()
+	fmt.Println("Commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-12s %s\n", name, commands[name].description)
+	}
+	fmt.Println()
+	fmt.Println("Options:")
 	flag.PrintDefaults()
 	os.Exit(exitCode)
 }
@@ -39,48 +69,169 @@ func init() {
 	log.SetFormatter(&log.TextFormatter{
 		FullTimestamp: true,
 	})
-	log.SetOutput(os.Stdout)
+	// stdout is reserved for the output of the commands, e.g. --format json and export
+	log.SetOutput(os.Stderr)
+}
+
+// parseFlags parses the command line, called by main rather than init so the flags of
+// go test are not mistaken for ours
+func parseFlags() {
+	flag.CommandLine.AddGoFlagSet(goflag.CommandLine)
+	flag.Parse()
 
 	if logLevel, err := log.ParseLevel(*logLevelArg); err == nil {
 		log.SetLevel(logLevel)
 		log.Infof("Log level set to \"%s\"", logLevel.String())
 	}
+}
 
-	flag.CommandLine.AddGoFlagSet(goflag.CommandLine)
-	flag.Parse()
+func isPostgresURL(path string) bool {
+	return strings.HasPrefix(path, "postgres://") || strings.HasPrefix(path, "postgresql://")
+}
 
-	if *helpArg {
-		usage(1)
+// openBlobStore returns the blob store selected by the command line, nil if contents are kept in the database
+func openBlobStore(ctx context.Context) (codefetcher.BlobStore, error) {
+	if len(*blobDirArg) > 0 && len(*s3EndpointArg) > 0 {
+		return nil, fmt.Errorf("--blob-dir and --s3-endpoint are mutually exclusive")
 	}
 
-	if len(*githubUserArg) == 0 {
-		log.Error("Missing argument github username")
-		usage(1)
+	if len(*blobDirArg) > 0 {
+		return codefetcher.NewDiskBlobStore(*blobDirArg)
 	}
 
-	if len(*githubTokenArg) == 0 {
-		log.Error("Missing argument github token")
-		usage(1)
+	if len(*s3EndpointArg) > 0 {
+		return codefetcher.NewS3BlobStore(ctx, codefetcher.S3Config{
+			Endpoint:  *s3EndpointArg,
+			AccessKey: *s3AccessKeyArg,
+			SecretKey: *s3SecretKeyArg,
+			Bucket:    *s3BucketArg,
+			Prefix:    *s3PrefixArg,
+			Insecure:  *s3InsecureArg,
+		})
 	}
 
-	if len(*languageArg) == 0 {
-		log.Error("Missing argument language")
-		usage(1)
-	} else {
-		var err error
-		language, err = codefetcher.ParseLanguage(*languageArg)
+	return nil, nil
+}
+
+// openStorage opens and initializes the SQLite database given by path,
+// the blob store is shared by all databases of a single invocation.
+func openStorage(ctx context.Context, path string) (codefetcher.Storage, error) {
+	if isPostgresURL(path) {
+		return codefetcher.Storage{}, fmt.Errorf("command requires an SQLite database, got %s", path)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return codefetcher.Storage{}, err
+	}
+
+	s := codefetcher.Storage{DB: db}
+	s.Blobs, err = openBlobStore(ctx)
+	if err != nil {
+		db.Close()
+		return codefetcher.Storage{}, err
+	}
+
+	err = s.Init(ctx)
+	if err != nil {
+		db.Close()
+		return codefetcher.Storage{}, err
+	}
+	return s, nil
+}
+
+// openReadOnlyStorage opens the SQLite database given by path read-only, e.g. the sources of
+// merge. The database is neither initialized nor migrated and has no blob store.
+func openReadOnlyStorage(ctx context.Context, path string) (codefetcher.Storage, error) {
+	if isPostgresURL(path) {
+		return codefetcher.Storage{}, fmt.Errorf("command requires an SQLite database, got %s", path)
+	}
+
+	// characters with a meaning in SQLite URIs are escaped
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	db, err := sql.Open("sqlite", "file:"+escaped+"?mode=ro")
+	if err != nil {
+		return codefetcher.Storage{}, err
+	}
+
+	s := codefetcher.Storage{DB: db}
+	if err = s.CheckSchema(ctx); err != nil {
+		db.Close()
+		return codefetcher.Storage{}, fmt.Errorf("%s: %w, run a command like stats on it to migrate it", path, err)
+	}
+	return s, nil
+}
+
+// openBackend opens the storage used for fetching, which is either SQLite or PostgreSQL,
+// PostgreSQL always stores the file contents in the database
+func openBackend(ctx context.Context, path string) (codefetcher.Backend, *sql.DB, error) {
+	if !isPostgresURL(path) {
+		s, err := openStorage(ctx, path)
+		return s, s.DB, err
+	}
+	if len(*blobDirArg) > 0 || len(*s3EndpointArg) > 0 {
+		return nil, nil, fmt.Errorf("--blob-dir and --s3-endpoint are only supported with SQLite databases")
+	}
+
+	db, err := sql.Open("postgres", path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := codefetcher.PostgresStorage{DB: db}
+	err = s.Init(ctx)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return s, db, nil
+}
+
+// parseLanguages parses a comma separated list of languages, an empty list selects all languages
+func parseLanguages(arg string) ([]codefetcher.Language, error) {
+	var languages []codefetcher.Language
+	for _, name := range strings.Split(arg, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		language, err := codefetcher.ParseLanguage(name)
 		if err != nil {
-			log.Error("Invalid argument language")
-			usage(1)
+			return nil, err
 		}
+		languages = append(languages, language)
 	}
+	return languages, nil
+}
 
-	if *requestTimeoutArg > 0 {
-		requestTimeout = time.Duration(*requestTimeoutArg) * time.Millisecond
-	}
+// printJSON writes v as indented JSON to stdout
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
 }
 
 func main() {
+	parseFlags()
+	if *helpArg {
+		usage(1)
+	}
+
+	name := defaultCommand
+	if flag.NArg() > 0 {
+		name = flag.Arg(0)
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		log.Errorf("Unknown command \"%s\"", name)
+		usage(1)
+	}
+
+	if err := loadLanguages(); err != nil {
+		log.Errorf("Failed to read language config: \"%s\"", err.Error())
+		usage(2)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Handle Ctrl+C
@@ -99,27 +250,8 @@ func main() {
 		<-signalChan // second signal, hard exit
 	}()
 
-	db, err := sql.Open("sqlite", *databaseArg)
-	if err != nil {
-		log.Errorf("Failed to open database: \"%s\"", err.Error())
-		usage(2)
-	}
-	defer db.Close()
-
-	s := codefetcher.Storage{DB: db}
-	err = s.Init(ctx)
-	if err != nil {
-		log.Errorf("Failed to initialize database: \"%s\"", err.Error())
-		usage(3)
-	}
-
-	log.Infof("Connected to database %s", *databaseArg)
-	log.Infof("Fetching code from github.com for language %s with query \"%s\"", language.String(), *queryArg)
-
-	fetcher := codefetcher.NewGithubFetcher(*githubUserArg, *githubTokenArg, s, requestTimeout)
-	err = fetcher.FetchCodes(ctx, language, *queryArg, *maxCodeSizeArg)
+	err := cmd.run(ctx)
 	if err != nil {
-		log.Fatalf("Failed to fetch codes: %s", err.Error())
-		usage(4)
+		log.Fatalf("Failed to %s: %s", name, err.Error())
 	}
 }
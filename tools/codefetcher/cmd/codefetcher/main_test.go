@@ -0,0 +1,16 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBackendRejectsBlobStore(t *testing.T) {
+	// rejected before connecting, PostgreSQL would silently keep the contents in the database
+	for _, flag := range []string{"--blob-dir", "--s3-endpoint"} {
+		_, stderr, err := execCommand("blocklist", "--database", "postgres://localhost:1/codefetcher", flag, t.TempDir())
+		if err == nil || !strings.Contains(string(stderr), "only supported with SQLite") {
+			t.Fatalf("Expected %s to be rejected with PostgreSQL, got %v\n%s", flag, err, stderr)
+		}
+	}
+}
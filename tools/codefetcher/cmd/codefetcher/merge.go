@@ -0,0 +1,118 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+var (
+	batchSizeArg *int = flag.Int("batch-size", codefetcher.DefaultMergeBatchSize, "merge, import, sample: Number of rows per transaction")
+)
+
+func init() {
+	registerCommand("merge", "Merge the given source databases into --database", runMerge)
+}
+
+// sourceMerge source of merge runs
+const sourceMerge = "merge"
+
+// mergeDatabases merges the source databases into target, the reports are summed up in total
+func mergeDatabases(ctx context.Context, target codefetcher.Storage, sources []string, total *codefetcher.MergeReport) error {
+	for _, path := range sources {
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+
+		source, err := openReadOnlyStorage(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Merging %s into %s", path, *databaseArg)
+		report, err := target.Merge(ctx, source, *batchSizeArg)
+		source.DB.Close()
+		*total = total.Add(report)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runMerge(ctx context.Context) error {
+	sources := flag.Args()[1:]
+	if len(sources) == 0 {
+		log.Error("Missing source databases")
+		usage(1)
+	}
+
+	target, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer target.DB.Close()
+
+	for _, path := range sources {
+		if samePath(path, *databaseArg) {
+			return fmt.Errorf("source %s is the target database", path)
+		}
+	}
+
+	run, err := startRun(ctx, target, sourceMerge, nil, nil)
+	if err != nil {
+		return err
+	}
+	target.RunID = run.ID
+
+	var total codefetcher.MergeReport
+	err = mergeDatabases(ctx, target, sources, &total)
+
+	summary := codefetcher.FetchSummary{Skipped: make(map[string]int)}
+	for _, count := range total.Languages {
+		summary.Stored += count.Inserted
+		summary.Duplicates += count.Duplicates
+		if count.Tombstoned > 0 {
+			summary.Skipped[codefetcher.SkipReasonTombstoned] += count.Tombstoned
+		}
+	}
+	if summaryErr := finishRun(ctx, target, run, summary, err); err != nil || summaryErr != nil {
+		if err == nil {
+			err = summaryErr
+		}
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(total)
+	}
+
+	var languages []string
+	for language := range total.Languages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "language\tinserted\tduplicates\ttombstoned\t")
+	for _, language := range languages {
+		count := total.Languages[language]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", language, count.Inserted, count.Duplicates, count.Tombstoned)
+	}
+	w.Flush()
+	log.Infof("Merged %d progress rows, %d tombstones and %d runs", total.Progress, total.Tombstones, total.Runs)
+	return nil
+}
+
+func samePath(a, b string) bool {
+	a, errA := filepath.Abs(a)
+	b, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && a == b
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeReadsSourcesOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	target, source, old := filepath.Join(dir, "target.db"), filepath.Join(dir, "source.db"), filepath.Join(dir, "old.db")
+	createTestDatabase(t, ctx, source, 3)
+	before, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("Error reading source: %v", err)
+	}
+
+	// the blob store of the target must not be used to read the source
+	runCommand(t, "merge", "--database", target, "--blob-dir", filepath.Join(dir, "blobs"), source)
+	after, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("Error reading source: %v", err)
+	}
+	if string(before) != string(after) {
+		t.Fatalf("Expected the source to be unchanged")
+	}
+	db, err := sql.Open("sqlite", target)
+	if err != nil {
+		t.Fatalf("Error opening target: %v", err)
+	}
+	defer db.Close()
+	if count, err := (codefetcher.Storage{DB: db}).CountCodefiles(ctx); err != nil || count != 3 {
+		t.Fatalf("Expected 3 merged codefiles, got %d (%v)", count, err)
+	}
+
+	// sources of an older version are rejected instead of being migrated
+	db, err = sql.Open("sqlite", old)
+	if err != nil {
+		t.Fatalf("Error opening database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.ExecContext(ctx, `CREATE TABLE "code" ("id" INTEGER, "language" TEXT NOT NULL, "url" TEXT NOT NULL,
+	"content" TEXT NOT NULL, "hash" TEXT NOT NULL UNIQUE, "size" INTEGER NOT NULL DEFAULT 0, PRIMARY KEY("id" AUTOINCREMENT));`)
+	if err != nil {
+		t.Fatalf("Error creating table: %v", err)
+	}
+	_, stderr, err := execCommand("merge", "--database", target, old)
+	if err == nil || !strings.Contains(string(stderr), codefetcher.ErrorOldSchema.Error()) {
+		t.Fatalf("Expected an outdated schema error, got %v\n%s", err, stderr)
+	}
+	var tables int
+	if err = db.QueryRowContext(ctx, `SELECT COUNT(name) FROM sqlite_schema WHERE type = 'table' AND name = 'progress';`).Scan(&tables); err != nil || tables != 0 {
+		t.Fatalf("Expected the old source not to be migrated, got %d progress tables (%v)", tables, err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	summaryArg *string = flag.String("summary", "", "fetch, import, merge: Write the run summary as JSON to this file")
+
+	// secretArgs flags whose values are never recorded in the runs table
+	secretArgs = map[string]bool{"github-token": true, "s3-access-key": true, "s3-secret-key": true}
+)
+
+func init() {
+	registerCommand("runs", "List the recorded fetch, import and merge runs", runRuns)
+}
+
+// redactedArguments returns the command line with the values of secret flags and URL passwords redacted
+func redactedArguments() []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretArgs[f.Name] {
+			value = "REDACTED"
+		} else if u, err := url.Parse(value); err == nil && u.User != nil {
+			value = u.Redacted()
+		}
+		args = append(args, fmt.Sprintf("--%s=%s", f.Name, value))
+	})
+	return append(args, flag.Args()...)
+}
+
+// startRun records the start of a run in s, the caller tags the code files it stores with the run id
+func startRun(ctx context.Context, s codefetcher.RunRecorder, source string, languages []codefetcher.Language, queries []string) (codefetcher.Run, error) {
+	run := codefetcher.Run{
+		Version:   version,
+		Arguments: redactedArguments(),
+		Source:    source,
+		Languages: []string{},
+		Queries:   append([]string{}, queries...),
+	}
+	for _, language := range languages {
+		run.Languages = append(run.Languages, language.String())
+	}
+
+	run, err := s.StartRun(ctx, run)
+	if err != nil {
+		return run, err
+	}
+	log.Infof("Started run %d", run.ID)
+	return run, nil
+}
+
+// finishRun records the summary of a run, logs it and writes it to --summary. A run
+// without id is not recorded in the database.
+func finishRun(ctx context.Context, s codefetcher.RunRecorder, run codefetcher.Run, summary codefetcher.FetchSummary, runErr error) error {
+	run.Summary = summary
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+
+	var err error
+	if run.ID > 0 {
+		// the run is recorded even if the command was canceled
+		if run, err = s.FinishRun(context.WithoutCancel(ctx), run); err != nil {
+			log.Errorf("Failed to record run %d: %s", run.ID, err.Error())
+		}
+	} else {
+		run.FinishedAt = time.Now().UTC().Truncate(time.Second)
+	}
+
+	log.Infof("Summary: searched=%d downloaded=%d stored=%d duplicates=%d", summary.Searched, summary.Downloaded, summary.Stored, summary.Duplicates)
+	var reasons []string
+	for reason := range summary.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		log.Infof("Summary: skipped %d code files: %s", summary.Skipped[reason], reason)
+	}
+	var mismatches []string
+	for mismatch := range summary.Mismatches {
+		mismatches = append(mismatches, mismatch)
+	}
+	sort.Strings(mismatches)
+	for _, mismatch := range mismatches {
+		log.Infof("Summary: language mismatch in %d code files: %s", summary.Mismatches[mismatch], mismatch)
+	}
+
+	if len(*summaryArg) > 0 {
+		data, err := json.MarshalIndent(run, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(*summaryArg, append(data, '\n'), 0o644); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func runRuns(ctx context.Context) error {
+	s, db, err := openBackend(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer db.Close()
+
+	runs, err := s.(codefetcher.RunRecorder).ListRuns(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		if runs == nil {
+			runs = []codefetcher.Run{}
+		}
+		return printJSON(runs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "id\tstarted\tduration\tversion\tsource\tlanguages\tsearched\tstored\tduplicates\tskipped\terror\t")
+	for _, run := range runs {
+		duration := "-"
+		if !run.FinishedAt.IsZero() {
+			duration = run.FinishedAt.Sub(run.StartedAt).String()
+		}
+		var skipped int
+		for _, count := range run.Summary.Skipped {
+			skipped += count
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n", run.ID, run.StartedAt.Format(time.RFC3339), duration, run.Version,
+			run.Source, strings.Join(run.Languages, ","), run.Summary.Searched, run.Summary.Stored, run.Summary.Duplicates, skipped, run.Error)
+	}
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	sampleUnitArg   *string = flag.String("sample-unit", string(codefetcher.SampleFiles), "sample: Balance languages by files, bytes or tokens")
+	sampleSizeArg   *int    = flag.Int("sample-size", 0, "sample: Amount per language in --sample-unit (0 = amount of the smallest language)")
+	stratifyRepoArg *bool   = flag.Bool("stratify-repo", false, "sample: Spread the sample evenly over repositories")
+	maxPerRepoArg   *int    = flag.Int("max-per-repo", 0, "sample: Maximum number of files per repository (0 = unlimited)")
+	manifestArg     *string = flag.String("manifest", "", "sample: Manifest file of the selected hashes (default: <output>.manifest.json)")
+)
+
+func init() {
+	registerCommand("sample", "Write a language-balanced sample to a database or export file (--sample-unit, --output)", runSample)
+}
+
+func runSample(ctx context.Context) error {
+	unit, err := codefetcher.ParseSampleUnit(*sampleUnitArg)
+	if err != nil {
+		log.Error("Invalid argument sample unit")
+		usage(1)
+	}
+
+	languages, err := parseLanguages(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+
+	splits, err := parseSplits(*splitArg)
+	if err != nil {
+		log.Error("Invalid argument split")
+		usage(1)
+	}
+
+	manifest := *manifestArg
+	if len(manifest) == 0 && *outputArg != "-" {
+		manifest = *outputArg + ".manifest.json"
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	sample, err := s.Sample(ctx, codefetcher.SampleOptions{
+		Filter: codefetcher.CodefileFilter{
+			Languages:     languages,
+			MinSize:       *minSizeArg,
+			MaxSize:       *maxSizeArg,
+			Splits:        splits,
+			SkipGenerated: *skipGeneratedArg,
+		},
+		Unit:                 unit,
+		Size:                 *sampleSizeArg,
+		Seed:                 *seedArg,
+		StratifyByRepository: *stratifyRepoArg,
+		MaxPerRepository:     *maxPerRepoArg,
+	})
+	if err != nil {
+		return err
+	}
+
+	var exporter codefetcher.Exporter
+	if strings.HasSuffix(*outputArg, ".db") {
+		target, err := createStorage(ctx, *outputArg)
+		if err != nil {
+			return err
+		}
+		defer target.DB.Close()
+		e := codefetcher.NewStorageExporter(ctx, target, *batchSizeArg)
+		if err = e.CopyRuns(s); err != nil {
+			return err
+		}
+		exporter = e
+	} else {
+		e, output, err := openExporter()
+		if err != nil {
+			return err
+		}
+		if output != nil {
+			defer output.Close()
+		}
+		exporter = e
+	}
+
+	count, err := s.ExportSample(ctx, sample, exporter)
+	if err != nil {
+		exporter.Close()
+		return err
+	}
+	if err = exporter.Close(); err != nil {
+		return err
+	}
+	log.Infof("Sampled %d code files to %s", count, *outputArg)
+
+	if len(manifest) > 0 {
+		f, err := os.Create(manifest)
+		if err != nil {
+			return err
+		}
+		if err = sample.WriteManifest(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		log.Infof("Wrote manifest to %s", manifest)
+	}
+
+	// stdout carries the sample itself
+	if *outputArg == "-" {
+		return nil
+	}
+
+	if *formatArg == "json" {
+		return printJSON(sample.Languages)
+	}
+
+	var names []string
+	for language := range sample.Languages {
+		names = append(names, language)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "language\tfiles\tbytes\ttokens\t")
+	for _, language := range names {
+		count := sample.Languages[language]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", language, count.Files, count.Bytes, count.Tokens)
+	}
+	w.Flush()
+	return nil
+}
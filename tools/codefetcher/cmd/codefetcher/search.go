@@ -0,0 +1,84 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"strings"
+)
+
+var (
+	limitArg        *int  = flag.Int("limit", codefetcher.DefaultSearchLimit, "search: Maximum number of results")
+	rebuildIndexArg *bool = flag.Bool("rebuild-index", false, "search: Rebuild the full-text search index before searching")
+)
+
+func init() {
+	registerCommand("search", "Full-text search over the stored code files (--query, --language)", runSearch)
+}
+
+func runSearch(ctx context.Context) error {
+	query := *queryArg
+	if flag.NArg() > 1 {
+		query = strings.Join(flag.Args()[1:], " ")
+	}
+	if len(query) == 0 {
+		log.Error("Missing argument query")
+		usage(1)
+	}
+
+	languages, err := parseLanguages(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	exists, err := s.SearchIndexExists(ctx)
+	if err != nil {
+		return err
+	}
+	if !exists || *rebuildIndexArg {
+		log.Infof("Building full-text search index, this may take a while...")
+		if err = s.BuildSearchIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	options := codefetcher.SearchOptions{
+		Query:          query,
+		Languages:      languages,
+		Limit:          *limitArg,
+		HighlightStart: "\033[1;31m",
+		HighlightEnd:   "\033[0m",
+	}
+	if *formatArg == "json" {
+		options.HighlightStart, options.HighlightEnd = "<mark>", "</mark>"
+	}
+
+	results, err := s.Search(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		if results == nil {
+			results = []codefetcher.SearchResult{}
+		}
+		return printJSON(results)
+	}
+
+	for _, r := range results {
+		fmt.Printf("[%s] %s (%d bytes)\n", r.Language, r.URL, r.Size)
+		fmt.Printf("    %s\n\n", strings.ReplaceAll(r.Snippet, "\n", "\n    "))
+	}
+	log.Infof("%d results", len(results))
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"text/tabwriter"
+)
+
+var (
+	seedTermsArg      *int = flag.Int("seed-terms", 50, "fetch, seeds: Number of frequent identifiers of the stored code files used as seed queries without --query")
+	maxSeedQueriesArg *int = flag.Int("max-seed-queries", 0, "fetch, seeds: Maximum number of seed queries (0 = unlimited)")
+)
+
+func init() {
+	registerCommand("seeds", "List the queries fetch generates for --language without --query", runSeeds)
+}
+
+// seedQueries generates the queries of language from its vocabulary and from the stored code
+// files, which are only mined from SQLite databases. Other backends use the vocabulary only.
+func seedQueries(ctx context.Context, s codefetcher.Backend, language codefetcher.Language) ([]codefetcher.SeedQuery, error) {
+	var mined []string
+	if *seedTermsArg > 0 {
+		if storage, ok := s.(codefetcher.Storage); ok {
+			var err error
+			if mined, err = codefetcher.MineSeedTerms(ctx, storage, language, *seedTermsArg, codefetcher.DefaultSeedFiles); err != nil {
+				return nil, err
+			}
+		} else {
+			log.Warnf("Seed terms are only mined from SQLite databases, using the %s vocabulary only", language)
+		}
+	}
+	return codefetcher.SeedQueries(language, codefetcher.DefaultSeedVocabularies[language.String()], mined, *maxSeedQueriesArg)
+}
+
+func runSeeds(ctx context.Context) error {
+	language, err := codefetcher.ParseLanguage(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	queries, err := seedQueries(ctx, s, language)
+	if err != nil {
+		return err
+	}
+	if *formatArg == "json" {
+		return printJSON(queries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "query\tsource\t")
+	for _, q := range queries {
+		fmt.Fprintf(w, "%s\t%s\t\n", q.Query, q.Source)
+	}
+	w.Flush()
+	return nil
+}
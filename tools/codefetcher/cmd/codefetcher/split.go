@@ -0,0 +1,104 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	outputDirArg *string = flag.StringP("output-dir", "o", ".", "split: Directory for the split databases")
+	shardsArg    *int    = flag.Int("shards", 0, "split: Number of hash-range shards (0 = one database per language)")
+)
+
+func init() {
+	registerCommand("split", "Split --database into one database per language or --shards hash-range shards", runSplit)
+}
+
+// createStorage creates a new database, existing files are never overwritten
+func createStorage(ctx context.Context, path string) (codefetcher.Storage, error) {
+	if _, err := os.Stat(path); err == nil {
+		return codefetcher.Storage{}, fmt.Errorf("database %s already exists", path)
+	}
+	return openStorage(ctx, path)
+}
+
+func runSplit(ctx context.Context) error {
+	if *shardsArg < 0 {
+		log.Error("Invalid argument shards")
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	if err = os.MkdirAll(*outputDirArg, 0o755); err != nil {
+		return err
+	}
+	base := strings.TrimSuffix(filepath.Base(*databaseArg), filepath.Ext(*databaseArg))
+
+	reports := make(map[string]codefetcher.MergeReport)
+	if *shardsArg == 0 {
+		reports, err = s.SplitByLanguage(ctx, func(language string) (codefetcher.Storage, error) {
+			path := filepath.Join(*outputDirArg, fmt.Sprintf("%s_%s.db", base, codefetcher.LanguageFileName(language)))
+			log.Infof("Writing %s files to %s", language, path)
+			return createStorage(ctx, path)
+		}, *batchSizeArg)
+	} else {
+		shards := make([]codefetcher.Storage, *shardsArg)
+		paths := make([]string, *shardsArg)
+		for i := range shards {
+			paths[i] = filepath.Join(*outputDirArg, fmt.Sprintf("%s_shard_%02d_of_%02d.db", base, i+1, *shardsArg))
+			shards[i], err = createStorage(ctx, paths[i])
+			if err != nil {
+				return err
+			}
+			defer shards[i].DB.Close()
+		}
+
+		var shardReports []codefetcher.MergeReport
+		shardReports, err = s.SplitByHash(ctx, shards, *batchSizeArg)
+		for i, report := range shardReports {
+			reports[paths[i]] = report
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		return printJSON(reports)
+	}
+
+	var names []string
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "output\tlanguage\tfiles\tprogress\t")
+	for _, name := range names {
+		report := reports[name]
+		var languages []string
+		for language := range report.Languages {
+			languages = append(languages, language)
+		}
+		sort.Strings(languages)
+		for _, language := range languages {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t\n", name, language, report.Languages[language].Inserted, report.Progress)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"codefetcher/codefetcher"
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+var (
+	topArg *int = flag.Int("top", codefetcher.DefaultStatsTopN, "stats: Number of top repositories and owners per language")
+)
+
+func init() {
+	registerCommand("stats", "Corpus statistics per language (--language, --max-code-size)", runStats)
+}
+
+func runStats(ctx context.Context) error {
+	languages, err := parseLanguages(*languageArg)
+	if err != nil {
+		log.Error("Invalid argument language")
+		usage(1)
+	}
+
+	splits, err := parseSplits(*splitArg)
+	if err != nil {
+		log.Error("Invalid argument split")
+		usage(1)
+	}
+
+	s, err := openStorage(ctx, *databaseArg)
+	if err != nil {
+		log.Errorf("Failed to open database: \"%s\"", err.Error())
+		usage(2)
+	}
+	defer s.DB.Close()
+
+	stats, err := s.Stats(ctx, codefetcher.StatsOptions{
+		Languages:     languages,
+		Splits:        splits,
+		SkipGenerated: *skipGeneratedArg,
+		TopN:          *topArg,
+		MaxCodeSize:   *maxCodeSizeArg,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *formatArg == "json" {
+		if stats == nil {
+			stats = []codefetcher.LanguageStats{}
+		}
+		return printJSON(stats)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "language\tfiles\ttotal bytes\tmedian bytes\tmedian lines\tp90 lines\tduplicates\ttarget\t")
+	for _, l := range stats {
+		target := "-"
+		if *maxCodeSizeArg > 0 {
+			target = fmt.Sprintf("%.1f%%", l.TargetReached)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f%%\t%s\t\n",
+			l.Language, l.Files, l.TotalBytes, l.MedianBytes, l.Lines.Median, l.Lines.P90, 100*l.DuplicateRate, target)
+	}
+	w.Flush()
+
+	for _, l := range stats {
+		fmt.Printf("\n%s\n", l.Language)
+
+		var buckets []string
+		for _, bucket := range l.SizeHistogram {
+			if bucket.UpperBound == 0 {
+				buckets = append(buckets, fmt.Sprintf(">%s: %d", formatBytes(statsLastBound(l.SizeHistogram)), bucket.Count))
+			} else {
+				buckets = append(buckets, fmt.Sprintf("<=%s: %d", formatBytes(bucket.UpperBound), bucket.Count))
+			}
+		}
+		fmt.Printf("  sizes:        %s\n", strings.Join(buckets, ", "))
+		fmt.Printf("  lines:        min=%d p25=%d median=%d p75=%d p90=%d max=%d mean=%.1f\n",
+			l.Lines.Min, l.Lines.P25, l.Lines.Median, l.Lines.P75, l.Lines.P90, l.Lines.Max, l.Lines.Mean)
+		fmt.Printf("  repositories: %s\n", formatRankedCounts(l.TopRepositories))
+		fmt.Printf("  owners:       %s\n", formatRankedCounts(l.TopOwners))
+		fmt.Printf("  splits:       %s\n", formatSplits(l.Splits))
+	}
+	return nil
+}
+
+func statsLastBound(histogram []codefetcher.HistogramBucket) int {
+	if len(histogram) < 2 {
+		return 0
+	}
+	return histogram[len(histogram)-2].UpperBound
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1024*1024 && n%(1024*1024) == 0:
+		return fmt.Sprintf("%dM", n/(1024*1024))
+	case n >= 1024 && n%1024 == 0:
+		return fmt.Sprintf("%dK", n/1024)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+func formatRankedCounts(counts []codefetcher.RankedCount) string {
+	var s []string
+	for _, c := range counts {
+		s = append(s, fmt.Sprintf("%s (%d)", c.Name, c.Files))
+	}
+	return strings.Join(s, ", ")
+}
+
+func formatSplits(counts map[string]int) string {
+	s := make([]string, 0, len(codefetcher.Splits)+1)
+	for _, split := range codefetcher.Splits {
+		s = append(s, fmt.Sprintf("%s=%d", split, counts[split]))
+	}
+	if counts[""] > 0 {
+		s = append(s, fmt.Sprintf("unassigned=%d", counts[""]))
+	}
+	return strings.Join(s, ", ")
+}
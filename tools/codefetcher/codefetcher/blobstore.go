@@ -0,0 +1,160 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobShardLength number of hex characters per shard directory (ab/cd/<hash>)
+const blobShardLength = 2
+
+var (
+	ErrorInvalidBlobKey = errors.New("invalid blob key")
+	ErrorBlobNotFound   = errors.New("blob not found")
+)
+
+// BlobStore stores file contents by their content hash
+type BlobStore interface {
+	Put(ctx context.Context, key string, content []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	Walk(ctx context.Context, fn func(key string) error) error
+}
+
+// BlobMetadata describes a stored blob, written as JSON sidecar by stores implementing BlobMetadataWriter
+type BlobMetadata struct {
+	Hash      string `json:"hash"`
+	Language  string `json:"language"`
+	URL       string `json:"url"`
+	Size      int    `json:"size"`
+	FetchedAt string `json:"fetched_at"`
+}
+
+// BlobMetadataWriter is implemented by blob stores that keep a metadata sidecar next to each blob
+type BlobMetadataWriter interface {
+	PutMetadata(ctx context.Context, key string, metadata BlobMetadata) error
+}
+
+// blobShards returns the shard directories of a blob key, e.g. ["ab", "cd"] for "abcdef..."
+func blobShards(key string) []string {
+	return []string{key[:blobShardLength], key[blobShardLength : 2*blobShardLength]}
+}
+
+// DiskBlobStore stores file contents outside the database in a sharded
+// content-addressed directory tree, e.g. "ab/cd/abcdef..." for key "abcdef...".
+type DiskBlobStore struct {
+	Root string
+}
+
+func NewDiskBlobStore(root string) (*DiskBlobStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskBlobStore{Root: root}, nil
+}
+
+func validBlobKey(key string) error {
+	if len(key) <= 2*blobShardLength {
+		return fmt.Errorf("%w: %s", ErrorInvalidBlobKey, key)
+	}
+	for _, c := range key {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return fmt.Errorf("%w: %s", ErrorInvalidBlobKey, key)
+		}
+	}
+	return nil
+}
+
+// Path returns the location of the blob on disk
+func (b DiskBlobStore) Path(key string) string {
+	return filepath.Join(append(append([]string{b.Root}, blobShards(key)...), key)...)
+}
+
+// Put writes content under key, blobs that already exist are left untouched
+func (b DiskBlobStore) Put(ctx context.Context, key string, content []byte) error {
+	if err := validBlobKey(key); err != nil {
+		return err
+	}
+
+	path := b.Path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so a crash never leaves a truncated blob behind
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (b DiskBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := validBlobKey(key); err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(b.Path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrorBlobNotFound, key)
+	}
+	return content, err
+}
+
+func (b DiskBlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := validBlobKey(key); err != nil {
+		return false, err
+	}
+	_, err := os.Stat(b.Path(key))
+	if err == nil {
+		return true, nil
+	} else if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (b DiskBlobStore) Delete(ctx context.Context, key string) error {
+	if err := validBlobKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(b.Path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Walk calls fn for the key of every blob in the store
+func (b DiskBlobStore) Walk(ctx context.Context, fn func(key string) error) error {
+	return filepath.WalkDir(b.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() || strings.Contains(d.Name(), ".tmp") {
+			return nil
+		}
+		return fn(d.Name())
+	})
+}
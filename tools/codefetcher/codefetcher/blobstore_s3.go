@@ -0,0 +1,157 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"io"
+	"path"
+	"strings"
+)
+
+// blobMetadataSuffix suffix of the JSON metadata sidecar object
+const blobMetadataSuffix = ".json"
+
+// S3BlobStore stores file contents as content-addressed objects in an
+// S3-compatible bucket, e.g. "<prefix>/ab/cd/abcdef..." with a JSON sidecar
+// "<prefix>/ab/cd/abcdef....json" holding the metadata of the file.
+type S3BlobStore struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// S3Config connection settings of an S3-compatible endpoint, e.g. a local MinIO
+type S3Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Prefix    string
+	Insecure  bool // use http instead of https
+}
+
+func NewS3BlobStore(ctx context.Context, config S3Config) (*S3BlobStore, error) {
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: !config.Insecure,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", config.Bucket)
+	}
+
+	return &S3BlobStore{client: client, bucket: config.Bucket, prefix: strings.Trim(config.Prefix, "/")}, nil
+}
+
+func (b S3BlobStore) objectName(key string) string {
+	return path.Join(append(append([]string{b.prefix}, blobShards(key)...), key)...)
+}
+
+// Put uploads content under key, objects that already exist are left untouched
+func (b S3BlobStore) Put(ctx context.Context, key string, content []byte) error {
+	exists, err := b.Exists(ctx, key)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = b.client.PutObject(ctx, b.bucket, b.objectName(key), bytes.NewReader(content), int64(len(content)),
+		minio.PutObjectOptions{ContentType: "text/plain; charset=utf-8"})
+	return err
+}
+
+func (b S3BlobStore) PutMetadata(ctx context.Context, key string, metadata BlobMetadata) error {
+	if err := validBlobKey(key); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.client.PutObject(ctx, b.bucket, b.objectName(key)+blobMetadataSuffix, bytes.NewReader(content), int64(len(content)),
+		minio.PutObjectOptions{ContentType: "application/json"})
+	return err
+}
+
+func (b S3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := validBlobKey(key); err != nil {
+		return nil, err
+	}
+
+	object, err := b.client.GetObject(ctx, b.bucket, b.objectName(key), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	content, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%w: %s", ErrorBlobNotFound, key)
+		}
+		return nil, err
+	}
+	return content, nil
+}
+
+func (b S3BlobStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := validBlobKey(key); err != nil {
+		return false, err
+	}
+
+	_, err := b.client.StatObject(ctx, b.bucket, b.objectName(key), minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes the blob and its metadata sidecar
+func (b S3BlobStore) Delete(ctx context.Context, key string) error {
+	if err := validBlobKey(key); err != nil {
+		return err
+	}
+
+	for _, name := range []string{b.objectName(key), b.objectName(key) + blobMetadataSuffix} {
+		if err := b.client.RemoveObject(ctx, b.bucket, name, minio.RemoveObjectOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for the key of every blob in the bucket, metadata sidecars are skipped
+func (b S3BlobStore) Walk(ctx context.Context, fn func(key string) error) error {
+	prefix := b.prefix
+	if len(prefix) > 0 {
+		prefix += "/"
+	}
+
+	for object := range b.client.ListObjects(ctx, b.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if strings.HasSuffix(object.Key, blobMetadataSuffix) {
+			continue
+		}
+		if err := fn(path.Base(object.Key)); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,91 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var _ BlobStore = DiskBlobStore{}
+
+func TestBlobStorePath(t *testing.T) {
+	b := DiskBlobStore{Root: "blobs"}
+
+	path := b.Path("abcdef0123")
+	expected := filepath.Join("blobs", "ab", "cd", "abcdef0123")
+	if path != expected {
+		t.Fatalf("Expected path %s, got %s", expected, path)
+	}
+}
+
+func TestBlobStoreInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewDiskBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating blob store: %v", err)
+	}
+
+	for _, key := range []string{"", "abcd", "../../etc/passwd", "ABCDEF0123"} {
+		if err = b.Put(ctx, key, testCodefileHelloWorld); !errors.Is(err, ErrorInvalidBlobKey) {
+			t.Errorf("Expected invalid blob key error for key \"%s\", got %v", key, err)
+		}
+	}
+}
+
+func TestBlobStorePutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	b, err := NewDiskBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating blob store: %v", err)
+	}
+
+	err = b.Put(ctx, testCodefileHelloWorldHash, testCodefileHelloWorld)
+	if err != nil {
+		t.Fatalf("Error writing blob: %v", err)
+	}
+
+	content, err := b.Get(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error reading blob: %v", err)
+	}
+	if !bytes.Equal(content, testCodefileHelloWorld) {
+		t.Fatalf("Expected content to be '%s', got '%s'", testCodefileHelloWorld, content)
+	}
+
+	var keys []string
+	err = b.Walk(ctx, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error walking blob store: %v", err)
+	}
+	if len(keys) != 1 || keys[0] != testCodefileHelloWorldHash {
+		t.Fatalf("Expected blob %s, got %v", testCodefileHelloWorldHash, keys)
+	}
+
+	err = b.Delete(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error deleting blob: %v", err)
+	}
+
+	exists, err := b.Exists(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error checking blob: %v", err)
+	}
+	if exists {
+		t.Fatalf("Expected blob to be deleted")
+	}
+
+	_, err = b.Get(ctx, testCodefileHelloWorldHash)
+	if !errors.Is(err, ErrorBlobNotFound) {
+		t.Fatalf("Expected error %s, got %v", ErrorBlobNotFound, err)
+	}
+
+	if _, err = os.Stat(b.Path(testCodefileHelloWorldHash)); !os.IsNotExist(err) {
+		t.Fatalf("Expected blob file to be removed")
+	}
+}
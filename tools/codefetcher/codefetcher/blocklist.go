@@ -0,0 +1,393 @@
+package codefetcher
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	sqlCreateTableBlocklist = `CREATE TABLE IF NOT EXISTS "blocklist" (
+	"kind"	TEXT NOT NULL,
+	"pattern"	TEXT NOT NULL,
+	"reason"	TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY("kind", "pattern")
+);`
+	sqlCreateTableTombstones = `CREATE TABLE IF NOT EXISTS "tombstones" (
+	"hash"	TEXT NOT NULL,
+	"url"	TEXT NOT NULL,
+	"reason"	TEXT NOT NULL DEFAULT '',
+	"purged_at"	TEXT NOT NULL,
+	PRIMARY KEY("hash")
+);`
+	sqlInsertBlocklist   = `INSERT OR REPLACE INTO blocklist (kind, pattern, reason) VALUES (?, ?, ?);`
+	sqlDeleteBlocklist   = `DELETE FROM blocklist WHERE kind = ? AND pattern = ?;`
+	sqlListBlocklist     = `SELECT kind, pattern, reason FROM blocklist ORDER BY kind, pattern;`
+	sqlInsertTombstone   = `INSERT INTO tombstones (hash, url, reason, purged_at) VALUES (?, ?, ?, ?) ON CONFLICT (hash) DO NOTHING;`
+	sqlTombstoneExists   = `SELECT COUNT(1) FROM tombstones WHERE hash = ?;`
+	sqlListTombstones    = `SELECT hash, url, reason, purged_at FROM tombstones ORDER BY hash;`
+	sqlDeleteCodeByID    = `DELETE FROM code WHERE id = ?;`
+	blocklistCommentChar = "#"
+)
+
+// fetch skip reasons, counted in FetchSummary.Skipped
+const (
+	SkipReasonBlocklisted = "blocklisted"
+	SkipReasonTombstoned  = "tombstoned"
+)
+
+// BlocklistKind what a blocklist pattern is matched against
+type BlocklistKind string
+
+const (
+	BlockOwner      BlocklistKind = "owner" // GitHub user or organization, e.g. "alice"
+	BlockRepository BlocklistKind = "repo"  // repository in owner/name notation, e.g. "alice/tools"
+	BlockPath       BlocklistKind = "path"  // glob on the file path, see MatchPathGlob
+)
+
+var (
+	ErrorInvalidBlocklistEntry = errors.New("invalid blocklist entry")
+)
+
+// BlocklistStore is implemented by the backends storing a blocklist, see Storage.AddBlocklist
+type BlocklistStore interface {
+	AddBlocklist(ctx context.Context, entries Blocklist) error
+	RemoveBlocklist(ctx context.Context, entries Blocklist) (int, error)
+	GetBlocklist(ctx context.Context) (Blocklist, error)
+	Purge(ctx context.Context, blocklist Blocklist, dryRun bool) (PurgeReport, error)
+}
+
+// BlocklistEntry a blocked owner, repository or path glob
+type BlocklistEntry struct {
+	Kind    BlocklistKind `json:"kind"`
+	Pattern string        `json:"pattern"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+func (e BlocklistEntry) String() string {
+	return string(e.Kind) + ":" + e.Pattern
+}
+
+// ParseBlocklistEntry parses an entry in "kind:pattern" notation, e.g. "repo:alice/tools"
+func ParseBlocklistEntry(s string) (BlocklistEntry, error) {
+	kind, pattern, ok := strings.Cut(strings.TrimSpace(s), ":")
+	pattern = strings.TrimSpace(pattern)
+	if !ok || len(pattern) == 0 {
+		return BlocklistEntry{}, fmt.Errorf("%w: %s", ErrorInvalidBlocklistEntry, s)
+	}
+
+	e := BlocklistEntry{Kind: BlocklistKind(strings.ToLower(strings.TrimSpace(kind))), Pattern: pattern}
+	switch e.Kind {
+	case BlockOwner:
+		if strings.Contains(pattern, "/") {
+			return BlocklistEntry{}, fmt.Errorf("%w: %s", ErrorInvalidBlocklistEntry, s)
+		}
+	case BlockRepository:
+		if parts := strings.Split(pattern, "/"); len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return BlocklistEntry{}, fmt.Errorf("%w: %s", ErrorInvalidBlocklistEntry, s)
+		}
+	case BlockPath:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return BlocklistEntry{}, fmt.Errorf("%w: %s", ErrorInvalidBlocklistEntry, s)
+		}
+	default:
+		return BlocklistEntry{}, fmt.Errorf("%w: %s", ErrorInvalidBlocklistEntry, s)
+	}
+	return e, nil
+}
+
+// Blocklist owners, repositories and paths which are never fetched
+type Blocklist []BlocklistEntry
+
+// ReadBlocklist reads a blocklist file with one "kind:pattern" entry per line. Empty lines
+// and lines starting with '#' are ignored, text after " #" is the reason of an entry.
+func ReadBlocklist(r io.Reader) (Blocklist, error) {
+	var blocklist Blocklist
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, blocklistCommentChar) {
+			continue
+		}
+
+		text, reason, _ := strings.Cut(text, " "+blocklistCommentChar)
+		e, err := ParseBlocklistEntry(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		e.Reason = strings.TrimSpace(reason)
+		blocklist = append(blocklist, e)
+	}
+	return blocklist, scanner.Err()
+}
+
+// Match returns the first entry blocking a file of repository at path, owners and repositories
+// are compared case-insensitively
+func (b Blocklist) Match(repository Repository, filePath string) (BlocklistEntry, bool) {
+	for _, e := range b {
+		switch e.Kind {
+		case BlockOwner:
+			if strings.EqualFold(e.Pattern, repository.Owner) {
+				return e, true
+			}
+		case BlockRepository:
+			if strings.EqualFold(e.Pattern, repository.String()) {
+				return e, true
+			}
+		case BlockPath:
+			if len(filePath) > 0 && MatchPathGlob(e.Pattern, filePath) {
+				return e, true
+			}
+		}
+	}
+	return BlocklistEntry{}, false
+}
+
+// MatchPathGlob reports whether a slash separated file path matches pattern. Patterns
+// without a slash match the file name, e.g. "*_pb2.py". Otherwise the pattern is matched
+// against the whole path, where a "**" segment matches any number of directories,
+// e.g. "**/vendor/**" matches every file below a vendor directory.
+func MatchPathGlob(pattern, filePath string) bool {
+	filePath = strings.Trim(filePath, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(filePath))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(filePath, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// AddBlocklist stores entries in the blocklist table, the reason of existing entries is updated
+func (s Storage) AddBlocklist(ctx context.Context, entries Blocklist) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	for _, e := range entries {
+		_, err := s.DB.ExecContext(ctx, sqlInsertBlocklist, string(e.Kind), e.Pattern, e.Reason)
+		if err != nil {
+			log.Debugf("Failed to add blocklist entry %s: %s", e, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveBlocklist removes entries from the blocklist table, returns the number of removed entries
+func (s Storage) RemoveBlocklist(ctx context.Context, entries Blocklist) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var removed int
+	for _, e := range entries {
+		result, err := s.DB.ExecContext(ctx, sqlDeleteBlocklist, string(e.Kind), e.Pattern)
+		if err != nil {
+			log.Debugf("Failed to remove blocklist entry %s: %s", e, err.Error())
+			return removed, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+func (s Storage) GetBlocklist(ctx context.Context) (Blocklist, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListBlocklist)
+	if err != nil {
+		log.Debugf("Failed to list blocklist: %s", err.Error())
+		return nil, err
+	}
+	return scanBlocklist(rows)
+}
+
+// scanBlocklist reads and closes rows of sqlListBlocklist
+func scanBlocklist(rows *sql.Rows) (Blocklist, error) {
+	defer rows.Close()
+
+	var blocklist Blocklist
+	for rows.Next() {
+		var e BlocklistEntry
+		if err := rows.Scan(&e.Kind, &e.Pattern, &e.Reason); err != nil {
+			return nil, err
+		}
+		blocklist = append(blocklist, e)
+	}
+	return blocklist, rows.Err()
+}
+
+// Tombstone a purged code file, its hash is never stored again
+type Tombstone struct {
+	Hash     string `json:"hash"`
+	URL      string `json:"url"`
+	Reason   string `json:"reason"`
+	PurgedAt string `json:"purged_at"`
+}
+
+func (s Storage) TombstoneExists(ctx context.Context, hash string) (bool, error) {
+	if s.DB == nil {
+		return false, ErrorNoDatabase
+	}
+	return tombstoneExists(ctx, s.DB, hash)
+}
+
+func tombstoneExists(ctx context.Context, db execer, hash string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, sqlTombstoneExists, hash).Scan(&exists)
+	if err != nil {
+		log.Debugf("Failed to check if tombstone exists: %s", err.Error())
+		return false, err
+	}
+	return exists, nil
+}
+
+func (s Storage) ListTombstones(ctx context.Context) ([]Tombstone, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListTombstones)
+	if err != nil {
+		log.Debugf("Failed to list tombstones: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tombstones []Tombstone
+	for rows.Next() {
+		var t Tombstone
+		if err = rows.Scan(&t.Hash, &t.URL, &t.Reason, &t.PurgedAt); err != nil {
+			return nil, err
+		}
+		tombstones = append(tombstones, t)
+	}
+	return tombstones, rows.Err()
+}
+
+func insertTombstone(ctx context.Context, db execer, t Tombstone) error {
+	_, err := db.ExecContext(ctx, sqlInsertTombstone, t.Hash, t.URL, t.Reason, t.PurgedAt)
+	if err != nil {
+		log.Debugf("Failed to insert tombstone %s: %s", t.Hash, err.Error())
+	}
+	return err
+}
+
+// PurgeReport purged code files by language
+type PurgeReport struct {
+	Languages map[string]int `json:"languages"`
+	Purged    int            `json:"purged"`
+}
+
+// Purge deletes all stored code files matching blocklist and leaves a tombstone for each,
+// so they are never fetched, merged or imported again. With dryRun set, the matching files
+// are only counted.
+func (s Storage) Purge(ctx context.Context, blocklist Blocklist, dryRun bool) (PurgeReport, error) {
+	report := PurgeReport{Languages: make(map[string]int)}
+	if s.DB == nil {
+		return report, ErrorNoDatabase
+	}
+
+	var purge []Codefile
+	var reasons []string
+	err := s.IterateCodefiles(ctx, CodefileFilter{SkipContent: true}, func(c Codefile) error {
+		if reason, ok := blocklist.matchURL(c.URL); ok {
+			purge = append(purge, c)
+			reasons = append(reasons, reason)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !dryRun {
+		if err = s.purgeCodefiles(ctx, purge, reasons); err != nil {
+			return report, err
+		}
+	}
+	report.add(purge)
+	return report, nil
+}
+
+// matchURL returns the matching entry of the url of a stored code file as tombstone reason,
+// files without a GitHub url are never matched
+func (b Blocklist) matchURL(url string) (string, bool) {
+	repository, filePath, err := ParseRepositoryURL(url)
+	if err != nil {
+		return "", false
+	}
+	e, ok := b.Match(repository, filePath)
+	return e.String(), ok
+}
+
+func (r *PurgeReport) add(purged []Codefile) {
+	for _, c := range purged {
+		r.Languages[c.Language]++
+	}
+	r.Purged += len(purged)
+}
+
+func (s Storage) purgeCodefiles(ctx context.Context, codefiles []Codefile, reasons []string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	purgedAt := time.Now().UTC().Format(time.RFC3339)
+	for i, c := range codefiles {
+		if err = insertTombstone(ctx, tx, Tombstone{Hash: c.Hash, URL: c.URL, Reason: reasons[i], PurgedAt: purgedAt}); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, sqlDeleteCodeByID, c.ID); err != nil {
+			log.Debugf("Failed to delete codefile %d: %s", c.ID, err.Error())
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// blobs are deleted once the rows are gone, a failure leaves orphans for check-blobs
+	if s.Blobs != nil {
+		for _, c := range codefiles {
+			if validBlobKey(c.Hash) != nil {
+				continue
+			}
+			if err = s.Blobs.Delete(ctx, c.Hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
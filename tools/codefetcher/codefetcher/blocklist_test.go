@@ -0,0 +1,194 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseBlocklistEntry(t *testing.T) {
+	tests := []struct {
+		entry string
+		valid bool
+	}{
+		{"owner:alice", true},
+		{"repo:alice/tools", true},
+		{"path:**/vendor/**", true},
+		{"owner:alice/tools", false},
+		{"repo:alice", false},
+		{"path:[", false},
+		{"user:alice", false},
+		{"alice", false},
+		{"owner:", false},
+	}
+	for _, test := range tests {
+		_, err := ParseBlocklistEntry(test.entry)
+		if test.valid && err != nil {
+			t.Errorf("Expected valid entry %s, got %v", test.entry, err)
+		} else if !test.valid && !errors.Is(err, ErrorInvalidBlocklistEntry) {
+			t.Errorf("Expected error %s for %s, got %v", ErrorInvalidBlocklistEntry, test.entry, err)
+		}
+	}
+}
+
+func TestReadBlocklist(t *testing.T) {
+	blocklist, err := ReadBlocklist(strings.NewReader("# opt-out requests\n\nowner:alice # mail from 2023-01-01\nrepo:bob/sdk\n"))
+	if err != nil {
+		t.Fatalf("Error reading blocklist: %v", err)
+	}
+	if len(blocklist) != 2 || blocklist[0].Reason != "mail from 2023-01-01" || blocklist[1].String() != "repo:bob/sdk" {
+		t.Fatalf("Unexpected blocklist %+v", blocklist)
+	}
+
+	if _, err = ReadBlocklist(strings.NewReader("owner:alice\nbob\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("Expected error in line 2, got %v", err)
+	}
+}
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"*_pb2.py", "proto/api_pb2.py", true},
+		{"*_pb2.py", "api_pb2.py", true},
+		{"*_pb2.py", "api.py", false},
+		{"**/vendor/**", "vendor/a/b.go", true},
+		{"**/vendor/**", "src/vendor/b.go", true},
+		{"**/vendor/**", "src/vendors/b.go", false},
+		{"src/*.py", "src/main.py", true},
+		{"src/*.py", "src/a/main.py", false},
+		{"src/**/*.py", "src/main.py", true},
+		{"src/**/*.py", "src/a/b/main.py", true},
+	}
+	for _, test := range tests {
+		if matched := MatchPathGlob(test.pattern, test.path); matched != test.expected {
+			t.Errorf("Expected %v for %s and %s, got %v", test.expected, test.pattern, test.path, matched)
+		}
+	}
+}
+
+func TestBlocklistMatch(t *testing.T) {
+	blocklist := Blocklist{
+		{Kind: BlockOwner, Pattern: "alice"},
+		{Kind: BlockRepository, Pattern: "bob/sdk"},
+		{Kind: BlockPath, Pattern: "*.min.js"},
+	}
+	tests := []struct {
+		repository Repository
+		path       string
+		expected   bool
+	}{
+		{Repository{Owner: "Alice", Name: "tools"}, "main.py", true},
+		{Repository{Owner: "bob", Name: "SDK"}, "main.py", true},
+		{Repository{Owner: "bob", Name: "web"}, "dist/app.min.js", true},
+		{Repository{Owner: "bob", Name: "web"}, "src/app.js", false},
+	}
+	for _, test := range tests {
+		if _, matched := blocklist.Match(test.repository, test.path); matched != test.expected {
+			t.Errorf("Expected %v for %s/%s, got %v", test.expected, test.repository, test.path, matched)
+		}
+	}
+}
+
+func TestBlocklistStorage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	entries := Blocklist{{Kind: BlockOwner, Pattern: "alice", Reason: "opt-out"}, {Kind: BlockRepository, Pattern: "bob/sdk"}}
+	if err := s.AddBlocklist(ctx, entries); err != nil {
+		t.Fatalf("Error adding blocklist entries: %v", err)
+	}
+	removed, err := s.RemoveBlocklist(ctx, Blocklist{{Kind: BlockRepository, Pattern: "bob/sdk"}, {Kind: BlockOwner, Pattern: "carol"}})
+	if err != nil {
+		t.Fatalf("Error removing blocklist entries: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Expected 1 removed entry, got %d", removed)
+	}
+
+	blocklist, err := s.GetBlocklist(ctx)
+	if err != nil {
+		t.Fatalf("Error getting blocklist: %v", err)
+	}
+	if len(blocklist) != 1 || blocklist[0] != entries[0] {
+		t.Fatalf("Expected blocklist %+v, got %+v", entries[:1], blocklist)
+	}
+}
+
+func storePurgeTestCodefiles(t *testing.T, ctx context.Context, s Storage) {
+	urls := []string{
+		"https://github.com/alice/tools/blob/0123/a.py",
+		"https://github.com/alice/web/blob/0123/b.py",
+		"https://github.com/bob/tools/blob/0123/c.py",
+		"http://localhost/d.py",
+	}
+	for i, url := range urls {
+		if err := s.StoreCodefile(ctx, testLanguage1, url, []byte(url), fmt.Sprintf("%040x", i+1)); err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, s := range []Storage{createTempDatabase(t), createTempBlobDatabase(t)} {
+		storePurgeTestCodefiles(t, ctx, s)
+		blocklist := Blocklist{{Kind: BlockOwner, Pattern: "alice"}}
+
+		report, err := s.Purge(ctx, blocklist, true)
+		if err != nil {
+			t.Fatalf("Error purging: %v", err)
+		}
+		if report.Purged != 2 {
+			t.Fatalf("Expected 2 purged files, got %d", report.Purged)
+		}
+		if count, _ := s.CountCodefiles(ctx); count != 4 {
+			t.Fatalf("Expected dry run to keep all files, got %d", count)
+		}
+
+		if _, err = s.Purge(ctx, blocklist, false); err != nil {
+			t.Fatalf("Error purging: %v", err)
+		}
+		if count, _ := s.CountCodefiles(ctx); count != 2 {
+			t.Fatalf("Expected 2 remaining files, got %d", count)
+		}
+		tombstoned, err := s.TombstoneExists(ctx, fmt.Sprintf("%040x", 1))
+		if err != nil {
+			t.Fatalf("Error checking tombstone: %v", err)
+		}
+		if !tombstoned {
+			t.Fatalf("Expected tombstone for the first file")
+		}
+
+		// purged files are not merged again, tombstones are copied to the target
+		source := createTempDatabase(t)
+		storePurgeTestCodefiles(t, ctx, source)
+		if _, err = s.Merge(ctx, source, 0); err != nil {
+			t.Fatalf("Error merging: %v", err)
+		}
+		if count, _ := s.CountCodefiles(ctx); count != 2 {
+			t.Fatalf("Expected 2 files after merge, got %d", count)
+		}
+
+		target := createTempDatabase(t)
+		merge, err := target.Merge(ctx, s, 0)
+		if err != nil {
+			t.Fatalf("Error merging: %v", err)
+		}
+		if merge.Tombstones != 2 {
+			t.Fatalf("Expected 2 merged tombstones, got %d", merge.Tombstones)
+		}
+		source.DB.Close()
+		target.DB.Close()
+		s.DB.Close()
+	}
+}
@@ -0,0 +1,224 @@
+package codefetcher
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+const (
+	sqlSelectCodefile        = `SELECT id, language, url, %s, hash, size, source, IFNULL(split, ''), IFNULL(generated, 0), IFNULL(run_id, 0), blob FROM code`
+	sqlGetCodefileByHash     = sqlSelectCodefile + ` WHERE hash = ?;`
+	sqlGetCodefileByID       = sqlSelectCodefile + ` WHERE id = ?;`
+	sqlListCodefiles         = sqlSelectCodefile + ` WHERE id > ?%s ORDER BY id LIMIT ?;`
+	sqlListLanguages         = `SELECT DISTINCT language FROM code ORDER BY language;`
+	sqlSelectContent         = `content`
+	sqlSelectEmptyContent    = `''`
+	defaultIterateBatchSize  = 500
+	defaultListCodefileLimit = 100
+)
+
+var (
+	ErrorCodefileNotFound = errors.New("codefile not found")
+)
+
+// Codefile a stored code file, Language holds the name as stored in the database (e.g. "C#")
+type Codefile struct {
+	ID        int64
+	Language  string
+	URL       string
+	Content   []byte
+	Hash      string
+	Size      int
+	Source    string // where the file was collected, e.g. SourceGithub or the name of an imported dataset
+	Split     string // dataset split, e.g. SplitTrain, empty if not assigned yet
+	Generated bool   // detected as generated by DetectGenerated when stored
+	RunID     int64  // run that fetched or imported the file, 0 if unknown
+}
+
+// CodefileFilter restricts which code files are read, the zero value selects all files
+type CodefileFilter struct {
+	Languages     []Language
+	MinSize       int      // minimum size in bytes
+	MaxSize       int      // maximum size in bytes, 0 for no limit
+	Splits        []string // only files assigned to these splits, all if empty
+	SkipGenerated bool     // skip files tagged as generated
+	SkipContent   bool     // only read the metadata, Content stays empty
+	untagged      bool     // only files not checked by DetectGenerated yet
+}
+
+func (f CodefileFilter) where() (string, []any) {
+	var conditions []string
+	var args []any
+	if len(f.Languages) > 0 {
+		placeholders := make([]string, len(f.Languages))
+		for i, language := range f.Languages {
+			placeholders[i] = "?"
+			args = append(args, language.String())
+		}
+		conditions = append(conditions, fmt.Sprintf("language IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if len(f.Splits) > 0 {
+		placeholders := make([]string, len(f.Splits))
+		for i, split := range f.Splits {
+			placeholders[i] = "?"
+			args = append(args, split)
+		}
+		conditions = append(conditions, fmt.Sprintf("split IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if f.SkipGenerated {
+		conditions = append(conditions, sqlNotGenerated)
+	}
+	if f.untagged {
+		conditions = append(conditions, sqlUntagged)
+	}
+	if f.MinSize > 0 {
+		conditions = append(conditions, "size >= ?")
+		args = append(args, f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		conditions = append(conditions, "size <= ?")
+		args = append(args, f.MaxSize)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conditions, " AND "), args
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanCodefile reads a row of sqlSelectCodefile and loads the content from the blob store if needed
+func (s Storage) scanCodefile(ctx context.Context, row rowScanner, skipContent bool) (Codefile, error) {
+	var c Codefile
+	var blob sql.NullString
+	err := row.Scan(&c.ID, &c.Language, &c.URL, &c.Content, &c.Hash, &c.Size, &c.Source, &c.Split, &c.Generated, &c.RunID, &blob)
+	if err != nil {
+		return Codefile{}, err
+	}
+
+	if blob.Valid && !skipContent {
+		if s.Blobs == nil {
+			return Codefile{}, ErrorNoBlobStore
+		}
+		c.Content, err = s.Blobs.Get(ctx, blob.String)
+		if err != nil {
+			return Codefile{}, err
+		}
+	}
+	return c, nil
+}
+
+func (s Storage) getCodefile(ctx context.Context, query string, arg any) (Codefile, error) {
+	if s.DB == nil {
+		return Codefile{}, ErrorNoDatabase
+	}
+	row := s.DB.QueryRowContext(ctx, fmt.Sprintf(query, sqlSelectContent), arg)
+	c, err := s.scanCodefile(ctx, row, false)
+	if err == sql.ErrNoRows {
+		return Codefile{}, fmt.Errorf("%w: %v", ErrorCodefileNotFound, arg)
+	} else if err != nil {
+		log.Debugf("Failed to get codefile %v: %s", arg, err.Error())
+		return Codefile{}, err
+	}
+	return c, nil
+}
+
+func (s Storage) GetCodefileByHash(ctx context.Context, hash string) (Codefile, error) {
+	return s.getCodefile(ctx, sqlGetCodefileByHash, hash)
+}
+
+func (s Storage) GetCodefileByID(ctx context.Context, id int64) (Codefile, error) {
+	return s.getCodefile(ctx, sqlGetCodefileByID, id)
+}
+
+// ListCodefiles returns up to limit code files with an id greater than cursor, ordered by id.
+// Pass the returned cursor to get the next page, it is 0 once all files have been read.
+func (s Storage) ListCodefiles(ctx context.Context, filter CodefileFilter, cursor int64, limit int) ([]Codefile, int64, error) {
+	if s.DB == nil {
+		return nil, 0, ErrorNoDatabase
+	}
+	if limit <= 0 {
+		limit = defaultListCodefileLimit
+	}
+
+	content := sqlSelectContent
+	if filter.SkipContent {
+		content = sqlSelectEmptyContent
+	}
+	where, args := filter.where()
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(sqlListCodefiles, content, where), append(append([]any{cursor}, args...), limit)...)
+	if err != nil {
+		log.Debugf("Failed to list codefiles: %s", err.Error())
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var codefiles []Codefile
+	for rows.Next() {
+		c, err := s.scanCodefile(ctx, rows, filter.SkipContent)
+		if err != nil {
+			return nil, 0, err
+		}
+		codefiles = append(codefiles, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if len(codefiles) < limit {
+		return codefiles, 0, nil
+	}
+	return codefiles, codefiles[len(codefiles)-1].ID, nil
+}
+
+// ListLanguages returns the names of all languages with stored code files
+func (s Storage) ListLanguages(ctx context.Context) ([]string, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListLanguages)
+	if err != nil {
+		log.Debugf("Failed to list languages: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var languages []string
+	for rows.Next() {
+		var language string
+		if err = rows.Scan(&language); err != nil {
+			return nil, err
+		}
+		languages = append(languages, language)
+	}
+	return languages, rows.Err()
+}
+
+// IterateCodefiles calls fn for every code file matching filter, ordered by id.
+// Files are read in small batches, so memory usage stays constant and fn may
+// write to the database in between. Iteration stops at the first error returned by fn.
+func (s Storage) IterateCodefiles(ctx context.Context, filter CodefileFilter, fn func(c Codefile) error) error {
+	var cursor int64
+	for {
+		codefiles, next, err := s.ListCodefiles(ctx, filter, cursor, defaultIterateBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, c := range codefiles {
+			if err = fn(c); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,144 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func storeTestCodefiles(t *testing.T, ctx context.Context, s Storage, language Language, n int) {
+	for i := 0; i < n; i++ {
+		content := []byte(fmt.Sprintf("print(%d)\n", i))
+		err := s.StoreCodefile(ctx, language, fmt.Sprintf("http://localhost/%s/%d", language, i), content, fmt.Sprintf("%040x", i+1000*len(language.String())))
+		if err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+}
+
+func TestGetCodefile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	for _, s := range []Storage{createTempDatabase(t), createTempBlobDatabase(t)} {
+		err := s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+		if err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+
+		c, err := s.GetCodefileByHash(ctx, testCodefileHelloWorldHash)
+		if err != nil {
+			t.Fatalf("Error getting codefile: %v", err)
+		}
+		if c.Language != testLanguage1.String() || c.URL != "http://localhost/main.py" || c.Size != len(testCodefileHelloWorld) {
+			t.Fatalf("Unexpected codefile %+v", c)
+		}
+		if !bytes.Equal(c.Content, testCodefileHelloWorld) {
+			t.Fatalf("Expected content to be '%s', got '%s'", testCodefileHelloWorld, c.Content)
+		}
+
+		c2, err := s.GetCodefileByID(ctx, c.ID)
+		if err != nil {
+			t.Fatalf("Error getting codefile: %v", err)
+		}
+		if c2.Hash != c.Hash || !bytes.Equal(c2.Content, c.Content) {
+			t.Fatalf("Expected %+v, got %+v", c, c2)
+		}
+
+		_, err = s.GetCodefileByHash(ctx, testCodefileHelloWorld2Hash)
+		if !errors.Is(err, ErrorCodefileNotFound) {
+			t.Fatalf("Expected error %s, got %v", ErrorCodefileNotFound, err)
+		}
+
+		s.DB.Close()
+	}
+}
+
+func TestListCodefiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	storeTestCodefiles(t, ctx, s, testLanguage1, 5)
+	storeTestCodefiles(t, ctx, s, testLanguage2, 3)
+
+	var cursor int64
+	var pages, total int
+	for {
+		codefiles, next, err := s.ListCodefiles(ctx, CodefileFilter{Languages: []Language{testLanguage1}}, cursor, 2)
+		if err != nil {
+			t.Fatalf("Error listing codefiles: %v", err)
+		}
+		for _, c := range codefiles {
+			if c.Language != testLanguage1.String() {
+				t.Fatalf("Expected language %s, got %s", testLanguage1, c.Language)
+			}
+			if c.ID <= cursor {
+				t.Fatalf("Expected id greater than cursor %d, got %d", cursor, c.ID)
+			}
+		}
+		pages++
+		total += len(codefiles)
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if total != 5 || pages != 3 {
+		t.Fatalf("Expected 5 codefiles on 3 pages, got %d on %d", total, pages)
+	}
+}
+
+func TestIterateCodefiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	storeTestCodefiles(t, ctx, s, testLanguage1, defaultIterateBatchSize+10)
+	storeTestCodefiles(t, ctx, s, testLanguage2, 3)
+
+	var count int
+	err := s.IterateCodefiles(ctx, CodefileFilter{}, func(c Codefile) error {
+		count++
+		if len(c.Content) == 0 {
+			return fmt.Errorf("codefile %d has no content", c.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error iterating codefiles: %v", err)
+	}
+	if count != defaultIterateBatchSize+13 {
+		t.Fatalf("Expected %d codefiles, got %d", defaultIterateBatchSize+13, count)
+	}
+
+	count = 0
+	err = s.IterateCodefiles(ctx, CodefileFilter{Languages: []Language{testLanguage2}, SkipContent: true}, func(c Codefile) error {
+		count++
+		if len(c.Content) != 0 {
+			return fmt.Errorf("codefile %d has content", c.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error iterating codefiles: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 codefiles, got %d", count)
+	}
+
+	errStop := errors.New("stop")
+	err = s.IterateCodefiles(ctx, CodefileFilter{}, func(c Codefile) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("Expected error %s, got %v", errStop, err)
+	}
+}
@@ -0,0 +1,222 @@
+package codefetcher
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"hash/fnv"
+	"math"
+	"strings"
+)
+
+const (
+	sqlCreateTableSplitConfig = `CREATE TABLE IF NOT EXISTS "split_config" (
+	"id"	INTEGER NOT NULL CHECK ("id" = 1),
+	"train"	REAL NOT NULL,
+	"validation"	REAL NOT NULL,
+	"test"	REAL NOT NULL,
+	"seed"	INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("id")
+);`
+	sqlGetSplitConfig    = `SELECT train, validation, test, seed FROM split_config WHERE id = 1;`
+	sqlUpdateSplitConfig = `INSERT OR REPLACE INTO split_config (id, train, validation, test, seed) VALUES (1, ?, ?, ?, ?);`
+	sqlListSplitURLs     = `SELECT id, url FROM code WHERE id > ?%s ORDER BY id LIMIT ?;`
+	sqlUnassignedSplit   = ` AND split IS NULL`
+	sqlUpdateSplit       = `UPDATE code SET split = ? WHERE id = ?;`
+	sqlCountSplits       = `SELECT IFNULL(split, ''), COUNT(id) FROM code GROUP BY split;`
+)
+
+// dataset splits a code file is assigned to
+const (
+	SplitTrain      = "train"
+	SplitValidation = "validation"
+	SplitTest       = "test"
+)
+
+// Splits all dataset splits in assignment order
+var Splits = []string{SplitTrain, SplitValidation, SplitTest}
+
+var (
+	ErrorInvalidSplitConfig = errors.New("invalid split config")
+)
+
+// SplitConfig ratios of the train, validation and test split, they are normalized to sum up to 1.
+// Changing the seed reshuffles the repositories between the splits.
+type SplitConfig struct {
+	Train      float64 `json:"train"`
+	Validation float64 `json:"validation"`
+	Test       float64 `json:"test"`
+	Seed       int64   `json:"seed"`
+}
+
+// DefaultSplitConfig used until a split config is stored
+var DefaultSplitConfig = SplitConfig{Train: 0.8, Validation: 0.1, Test: 0.1}
+
+func (c SplitConfig) validate() error {
+	for _, ratio := range []float64{c.Train, c.Validation, c.Test} {
+		if ratio < 0 || math.IsNaN(ratio) || math.IsInf(ratio, 0) {
+			return fmt.Errorf("%w: ratios must not be negative", ErrorInvalidSplitConfig)
+		}
+	}
+	if c.Train+c.Validation+c.Test <= 0 {
+		return fmt.Errorf("%w: ratios must not all be zero", ErrorInvalidSplitConfig)
+	}
+	return nil
+}
+
+// Assign returns the split of a code file. All files of a repository end up in the same
+// split, so no repository leaks between train and test. Files without a repository in
+// their url are assigned by url.
+func (c SplitConfig) Assign(url string) string {
+	key := url
+	if repository, _, err := ParseRepositoryURL(url); err == nil {
+		key = strings.ToLower(repository.String())
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s", c.Seed, key)
+	position := float64(h.Sum64()) / math.Exp2(64) * (c.Train + c.Validation + c.Test)
+
+	switch {
+	case position < c.Train:
+		return SplitTrain
+	case position < c.Train+c.Validation:
+		return SplitValidation
+	}
+	return SplitTest
+}
+
+// GetSplitConfig returns the stored split config, DefaultSplitConfig if none is stored
+func (s Storage) GetSplitConfig(ctx context.Context) (SplitConfig, error) {
+	if s.DB == nil {
+		return SplitConfig{}, ErrorNoDatabase
+	}
+	return getSplitConfig(ctx, s.DB)
+}
+
+func getSplitConfig(ctx context.Context, db execer) (SplitConfig, error) {
+	var c SplitConfig
+	err := db.QueryRowContext(ctx, sqlGetSplitConfig).Scan(&c.Train, &c.Validation, &c.Test, &c.Seed)
+	if err == sql.ErrNoRows {
+		return DefaultSplitConfig, nil
+	} else if err != nil {
+		log.Debugf("Failed to get split config: %s", err.Error())
+		return SplitConfig{}, err
+	}
+	return c, nil
+}
+
+// SetSplitConfig stores the split config, existing assignments are only
+// updated by AssignSplits.
+func (s Storage) SetSplitConfig(ctx context.Context, c SplitConfig) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	if err := c.validate(); err != nil {
+		return err
+	}
+	_, err := s.DB.ExecContext(ctx, sqlUpdateSplitConfig, c.Train, c.Validation, c.Test, c.Seed)
+	if err != nil {
+		log.Debugf("Failed to update split config VALUES(%f, %f, %f, %d): %s", c.Train, c.Validation, c.Test, c.Seed, err.Error())
+	}
+	return err
+}
+
+// AssignSplits assigns code files to splits using the stored split config. Only files without
+// a split are assigned unless all is set, which is needed after changing the config.
+// Returns the number of assigned files by split.
+func (s Storage) AssignSplits(ctx context.Context, all bool) (map[string]int, error) {
+	counts := make(map[string]int)
+	if s.DB == nil {
+		return counts, ErrorNoDatabase
+	}
+
+	config, err := s.GetSplitConfig(ctx)
+	if err != nil {
+		return counts, err
+	}
+
+	where := sqlUnassignedSplit
+	if all {
+		where = ""
+	}
+	query := fmt.Sprintf(sqlListSplitURLs, where)
+
+	var cursor int64
+	for {
+		rows, err := s.DB.QueryContext(ctx, query, cursor, defaultIterateBatchSize)
+		if err != nil {
+			log.Debugf("Failed to list codefiles: %s", err.Error())
+			return counts, err
+		}
+		splits := make(map[int64]string)
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			var url string
+			if err = rows.Scan(&id, &url); err != nil {
+				rows.Close()
+				return counts, err
+			}
+			splits[id] = config.Assign(url)
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return counts, err
+		}
+		if len(ids) == 0 {
+			return counts, nil
+		}
+
+		if err = s.updateSplits(ctx, ids, splits); err != nil {
+			return counts, err
+		}
+		for _, split := range splits {
+			counts[split]++
+		}
+		cursor = ids[len(ids)-1]
+	}
+}
+
+func (s Storage) updateSplits(ctx context.Context, ids []int64, splits map[int64]string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err = tx.ExecContext(ctx, sqlUpdateSplit, splits[id], id); err != nil {
+			log.Debugf("Failed to update split of codefile %d: %s", id, err.Error())
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CountSplits returns the number of code files by split, unassigned files are counted under ""
+func (s Storage) CountSplits(ctx context.Context) (map[string]int, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlCountSplits)
+	if err != nil {
+		log.Debugf("Failed to count splits: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var split string
+		var count int
+		if err = rows.Scan(&split, &count); err != nil {
+			return nil, err
+		}
+		counts[split] = count
+	}
+	return counts, rows.Err()
+}
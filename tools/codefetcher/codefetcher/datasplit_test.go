@@ -0,0 +1,110 @@
+package codefetcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSplitConfigAssign(t *testing.T) {
+	config := SplitConfig{Train: 0.6, Validation: 0.2, Test: 0.2, Seed: 42}
+
+	// all files of a repository share a split, regardless of case and path
+	split := config.Assign("https://github.com/alice/tools/blob/0123/a.py")
+	for _, url := range []string{
+		"https://github.com/alice/tools/blob/4567/src/b.py",
+		"https://github.com/Alice/Tools/blob/0123/c.py",
+	} {
+		if s := config.Assign(url); s != split {
+			t.Fatalf("Expected split %s for %s, got %s", split, url, s)
+		}
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[config.Assign(fmt.Sprintf("https://github.com/owner%d/repo/blob/0123/main.py", i))]++
+	}
+	for split, expected := range map[string]int{SplitTrain: 6000, SplitValidation: 2000, SplitTest: 2000} {
+		if counts[split] < expected-300 || counts[split] > expected+300 {
+			t.Errorf("Expected about %d repositories in %s, got %d", expected, split, counts[split])
+		}
+	}
+
+	if s := (SplitConfig{Train: 0, Validation: 0, Test: 1}).Assign("https://github.com/alice/tools"); s != SplitTest {
+		t.Fatalf("Expected split %s, got %s", SplitTest, s)
+	}
+}
+
+func TestSetSplitConfigInvalid(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	for _, config := range []SplitConfig{{}, {Train: -1, Test: 2}} {
+		if err := s.SetSplitConfig(ctx, config); err == nil {
+			t.Fatalf("Expected error for split config %+v", config)
+		}
+	}
+}
+
+func TestAssignSplits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	config, err := s.GetSplitConfig(ctx)
+	if err != nil {
+		t.Fatalf("Error getting split config: %v", err)
+	}
+	if config != DefaultSplitConfig {
+		t.Fatalf("Expected default split config, got %+v", config)
+	}
+
+	// new files are assigned on insert
+	storeTestCodefiles(t, ctx, s, testLanguage1, 5)
+	c, err := s.GetCodefileByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("Error getting codefile: %v", err)
+	}
+	if c.Split != DefaultSplitConfig.Assign(c.URL) {
+		t.Fatalf("Expected split %s, got %s", DefaultSplitConfig.Assign(c.URL), c.Split)
+	}
+
+	// files stored before the split column existed have no split
+	if _, err = s.DB.ExecContext(ctx, `UPDATE code SET split = NULL WHERE id <= 2;`); err != nil {
+		t.Fatalf("Error resetting splits: %v", err)
+	}
+	counts, err := s.AssignSplits(ctx, false)
+	if err != nil {
+		t.Fatalf("Error assigning splits: %v", err)
+	}
+	if counts[SplitTrain]+counts[SplitValidation]+counts[SplitTest] != 2 {
+		t.Fatalf("Expected 2 assigned files, got %v", counts)
+	}
+
+	if err = s.SetSplitConfig(ctx, SplitConfig{Test: 1, Seed: 7}); err != nil {
+		t.Fatalf("Error setting split config: %v", err)
+	}
+	if _, err = s.AssignSplits(ctx, true); err != nil {
+		t.Fatalf("Error assigning splits: %v", err)
+	}
+	counts, err = s.CountSplits(ctx)
+	if err != nil {
+		t.Fatalf("Error counting splits: %v", err)
+	}
+	if len(counts) != 1 || counts[SplitTest] != 5 {
+		t.Fatalf("Expected all files in %s, got %v", SplitTest, counts)
+	}
+
+	codefiles, _, err := s.ListCodefiles(ctx, CodefileFilter{Splits: []string{SplitTrain}}, 0, 10)
+	if err != nil {
+		t.Fatalf("Error listing codefiles: %v", err)
+	}
+	if len(codefiles) != 0 {
+		t.Fatalf("Expected no %s files, got %d", SplitTrain, len(codefiles))
+	}
+}
@@ -0,0 +1,257 @@
+package codefetcher
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/parquet-go/parquet-go"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// parquetRowGroupSize flush a parquet row group once this many content bytes are buffered,
+// keeps memory usage constant for languages with lots of code
+const parquetRowGroupSize = 64 * 1024 * 1024
+
+// ExportRecord a code file as written by the JSONL and Parquet exporters
+type ExportRecord struct {
+	ID        int64  `json:"id" parquet:"id"`
+	Language  string `json:"language" parquet:"language,dict"`
+	URL       string `json:"url" parquet:"url"`
+	Hash      string `json:"hash" parquet:"hash"`
+	Size      int64  `json:"size" parquet:"size"`
+	Source    string `json:"source" parquet:"source,dict"`
+	Split     string `json:"split" parquet:"split,dict"`
+	Generated bool   `json:"generated" parquet:"generated"`
+	Content   string `json:"content" parquet:"content,zstd"`
+}
+
+func newExportRecord(c Codefile) ExportRecord {
+	return ExportRecord{
+		ID:        c.ID,
+		Language:  c.Language,
+		URL:       c.URL,
+		Hash:      c.Hash,
+		Size:      int64(c.Size),
+		Source:    c.Source,
+		Split:     c.Split,
+		Generated: c.Generated,
+		Content:   string(c.Content),
+	}
+}
+
+// Exporter writes code files to an export format, files are passed grouped by language
+type Exporter interface {
+	Write(c Codefile) error
+	Close() error
+}
+
+// JSONLExporter writes one JSON object per line, optionally gzip compressed
+type JSONLExporter struct {
+	buffer  *bufio.Writer
+	gzip    *gzip.Writer
+	encoder *json.Encoder
+}
+
+func NewJSONLExporter(w io.Writer, compress bool) *JSONLExporter {
+	e := &JSONLExporter{}
+	if compress {
+		e.gzip = gzip.NewWriter(w)
+		w = e.gzip
+	}
+	e.buffer = bufio.NewWriter(w)
+	e.encoder = json.NewEncoder(e.buffer)
+	return e
+}
+
+func (e *JSONLExporter) Write(c Codefile) error {
+	return e.encoder.Encode(newExportRecord(c))
+}
+
+func (e *JSONLExporter) Close() error {
+	if err := e.buffer.Flush(); err != nil {
+		return err
+	}
+	if e.gzip != nil {
+		return e.gzip.Close()
+	}
+	return nil
+}
+
+// ParquetExporter writes a Parquet file with separate row groups per language
+type ParquetExporter struct {
+	writer   *parquet.GenericWriter[ExportRecord]
+	language string
+	buffered int
+}
+
+func NewParquetExporter(w io.Writer) *ParquetExporter {
+	return &ParquetExporter{writer: parquet.NewGenericWriter[ExportRecord](w)}
+}
+
+func (e *ParquetExporter) Write(c Codefile) error {
+	if (c.Language != e.language || e.buffered >= parquetRowGroupSize) && e.buffered > 0 {
+		if err := e.writer.Flush(); err != nil {
+			return err
+		}
+		e.buffered = 0
+	}
+	e.language = c.Language
+	e.buffered += len(c.Content)
+
+	_, err := e.writer.Write([]ExportRecord{newExportRecord(c)})
+	return err
+}
+
+func (e *ParquetExporter) Close() error {
+	return e.writer.Close()
+}
+
+// TreeExporter writes every code file to <root>/<language>/<owner>/<repo>/<path>, files
+// without a repository url go to <root>/<language>/_unknown/<hash>
+type TreeExporter struct {
+	Root string
+}
+
+func NewTreeExporter(root string) (*TreeExporter, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &TreeExporter{Root: root}, nil
+}
+
+func (e *TreeExporter) Write(c Codefile) error {
+	parts := []string{LanguageFileName(c.Language)}
+	repository, path, err := ParseRepositoryURL(c.URL)
+	if err == nil && len(path) > 0 {
+		parts = append(parts, repository.Owner, repository.Name)
+		parts = append(parts, strings.Split(path, "/")...)
+	} else {
+		parts = append(parts, "_unknown", c.Hash)
+	}
+
+	for _, part := range parts {
+		if len(part) == 0 || part == "." || part == ".." {
+			return fmt.Errorf("invalid path for %s", c.URL)
+		}
+	}
+	target := filepath.Join(append([]string{e.Root}, parts...)...)
+
+	if err = os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	// the same path may be stored twice with different contents, keep both
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		f, err = os.OpenFile(fmt.Sprintf("%s.%s", target, c.Hash), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(c.Content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (e *TreeExporter) Close() error {
+	return nil
+}
+
+// StorageExporter writes code files to another database in transactions of BatchSize files,
+// files with an already stored hash are counted as duplicates in Report
+type StorageExporter struct {
+	ctx       context.Context
+	target    Storage
+	batchSize int
+	batch     []Codefile
+	runs      map[int64]int64
+	Report    MergeReport
+}
+
+func NewStorageExporter(ctx context.Context, target Storage, batchSize int) *StorageExporter {
+	if batchSize <= 0 {
+		batchSize = DefaultMergeBatchSize
+	}
+	return &StorageExporter{
+		ctx:       ctx,
+		target:    target,
+		batchSize: batchSize,
+		Report:    MergeReport{Languages: make(map[string]*MergeCount)},
+	}
+}
+
+// CopyRuns copies the runs of source to the target, so written files keep the run that
+// fetched them. Without it the run of written files is dropped.
+func (e *StorageExporter) CopyRuns(source Storage) error {
+	runs, err := e.target.copyRuns(e.ctx, source)
+	if err != nil {
+		return err
+	}
+	e.runs = runs
+	e.Report.Runs = len(runs)
+	return nil
+}
+
+func (e *StorageExporter) Write(c Codefile) error {
+	e.batch = append(e.batch, c)
+	if len(e.batch) < e.batchSize {
+		return nil
+	}
+	return e.flush()
+}
+
+func (e *StorageExporter) flush() error {
+	if len(e.batch) == 0 {
+		return nil
+	}
+	remapRuns(e.batch, e.runs)
+	err := e.target.mergeCodefiles(e.ctx, e.batch, e.Report)
+	e.batch = e.batch[:0]
+	return err
+}
+
+func (e *StorageExporter) Close() error {
+	return e.flush()
+}
+
+// Export writes all code files matching filter to e, grouped by language. Files are
+// streamed from the database, so memory usage does not depend on the corpus size.
+// Returns the number of exported files.
+func (s Storage) Export(ctx context.Context, filter CodefileFilter, e Exporter) (int, error) {
+	languages, err := s.ListLanguages(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	selected := make(map[string]bool)
+	for _, language := range filter.Languages {
+		selected[language.String()] = true
+	}
+
+	var count int
+	for _, language := range languages {
+		if len(selected) > 0 && !selected[language] {
+			continue
+		}
+
+		languageFilter := filter
+		languageFilter.Languages = []Language{newLanguage(LanguageDefinition{Name: language})}
+		err = s.IterateCodefiles(ctx, languageFilter, func(c Codefile) error {
+			count++
+			return e.Write(c)
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,164 @@
+package codefetcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/parquet-go/parquet-go"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func storeExportTestCodefiles(t *testing.T, ctx context.Context, s Storage) {
+	files := []struct {
+		language Language
+		url      string
+		content  []byte
+		hash     string
+	}{
+		{testLanguage2, "https://github.com/alice/tools/blob/0123/src/A.cs", []byte("class A {}\n"), "e1"},
+		{testLanguage1, "https://github.com/alice/tools/blob/0123/main.py", testCodefileHelloWorld, "e2"},
+		{testLanguage1, "https://github.com/bob/big/blob/0123/big.py", bytes.Repeat([]byte("x = 1\n"), 1000), "e3"},
+		{testLanguage2, "http://localhost/B.cs", []byte("class B {}\n"), "e4"},
+	}
+	for _, f := range files {
+		if err := s.StoreCodefile(ctx, f.language, f.url, f.content, f.hash); err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+	storeExportTestCodefiles(t, ctx, s)
+
+	var buffer bytes.Buffer
+	e := NewJSONLExporter(&buffer, true)
+	count, err := s.Export(ctx, CodefileFilter{MaxSize: 1000}, e)
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatalf("Error closing exporter: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected 3 exported files, got %d", count)
+	}
+
+	reader, err := gzip.NewReader(&buffer)
+	if err != nil {
+		t.Fatalf("Error reading gzip: %v", err)
+	}
+	decoder := json.NewDecoder(reader)
+	var languages []string
+	for decoder.More() {
+		var record ExportRecord
+		if err = decoder.Decode(&record); err != nil {
+			t.Fatalf("Error decoding record: %v", err)
+		}
+		if int(record.Size) != len(record.Content) {
+			t.Fatalf("Expected %d bytes of content, got %d", record.Size, len(record.Content))
+		}
+		languages = append(languages, record.Language)
+	}
+
+	expected := []string{testLanguage2.String(), testLanguage2.String(), testLanguage1.String()}
+	if len(languages) != len(expected) {
+		t.Fatalf("Expected languages %v, got %v", expected, languages)
+	}
+	for i := range expected {
+		if languages[i] != expected[i] {
+			t.Fatalf("Expected languages %v, got %v", expected, languages)
+		}
+	}
+}
+
+func TestExportParquet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+	storeExportTestCodefiles(t, ctx, s)
+
+	path := filepath.Join(t.TempDir(), "codes.parquet")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Error creating file: %v", err)
+	}
+	e := NewParquetExporter(f)
+	count, err := s.Export(ctx, CodefileFilter{}, e)
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatalf("Error closing exporter: %v", err)
+	}
+	f.Close()
+	if count != 4 {
+		t.Fatalf("Expected 4 exported files, got %d", count)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatalf("Error opening file: %v", err)
+	}
+	defer f.Close()
+	stat, _ := f.Stat()
+	file, err := parquet.OpenFile(f, stat.Size())
+	if err != nil {
+		t.Fatalf("Error reading parquet file: %v", err)
+	}
+	if len(file.RowGroups()) != 2 {
+		t.Fatalf("Expected a row group per language, got %d", len(file.RowGroups()))
+	}
+
+	rows, err := parquet.Read[ExportRecord](f, stat.Size())
+	if err != nil {
+		t.Fatalf("Error reading parquet rows: %v", err)
+	}
+	if len(rows) != 4 || rows[3].Hash != "e3" || rows[3].Size != int64(len(rows[3].Content)) {
+		t.Fatalf("Unexpected rows %+v", rows)
+	}
+}
+
+func TestExportTree(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+	storeExportTestCodefiles(t, ctx, s)
+
+	root := t.TempDir()
+	e, err := NewTreeExporter(root)
+	if err != nil {
+		t.Fatalf("Error creating exporter: %v", err)
+	}
+	if _, err = s.Export(ctx, CodefileFilter{Languages: []Language{testLanguage2}}, e); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+
+	for path, expected := range map[string]string{
+		filepath.Join(root, "csharp", "alice", "tools", "src", "A.cs"): "class A {}\n",
+		filepath.Join(root, "csharp", "_unknown", "e4"):                "class B {}\n",
+	} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Error reading exported file: %v", err)
+		}
+		if string(content) != expected {
+			t.Fatalf("Expected content '%s' in %s, got '%s'", expected, path, content)
+		}
+	}
+
+	if _, err = os.Stat(filepath.Join(root, "python")); !os.IsNotExist(err) {
+		t.Fatalf("Expected no python files to be exported")
+	}
+}
@@ -3,7 +3,6 @@ package codefetcher
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/google/go-github/github"
 	log "github.com/sirupsen/logrus"
 	"github.com/softlandia/cpd"
@@ -11,6 +10,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -25,11 +25,47 @@ var (
 
 type GithubFetcher struct {
 	client         *github.Client
-	storage        Storage
+	storage        Backend
 	requestTimeout time.Duration
+	Limits         FetchLimits
+	Blocklist      Blocklist
+	Filters        Filters // applied to downloaded code files, rejects are counted by filter name
+	Paths          PathRules
+	VerifyLanguage bool // check the language of downloaded code files with VerifyLanguage
 }
 
-func NewGithubFetcher(githubUser, githubAccessToken string, storage Storage, requestTimeout time.Duration) GithubFetcher {
+// FetchSummary counters of FetchCodes, skipped code results by skip reason
+type FetchSummary struct {
+	Searched   int            `json:"searched"`   // code results returned by the search
+	Downloaded int            `json:"downloaded"` // code files downloaded
+	Stored     int            `json:"stored"`
+	Duplicates int            `json:"duplicates"` // code results with an already stored hash
+	Skipped    map[string]int `json:"skipped"`
+	Mismatches map[string]int `json:"mismatches,omitempty"` // code files with a language detected by VerifyLanguage, see LanguageMismatch
+}
+
+// Add sums up the counters of another summary
+func (s FetchSummary) Add(other FetchSummary) FetchSummary {
+	sum := func(maps ...map[string]int) map[string]int {
+		result := make(map[string]int)
+		for _, m := range maps {
+			for key, count := range m {
+				result[key] += count
+			}
+		}
+		return result
+	}
+	return FetchSummary{
+		Searched:   s.Searched + other.Searched,
+		Downloaded: s.Downloaded + other.Downloaded,
+		Stored:     s.Stored + other.Stored,
+		Duplicates: s.Duplicates + other.Duplicates,
+		Skipped:    sum(s.Skipped, other.Skipped),
+		Mismatches: sum(s.Mismatches, other.Mismatches),
+	}
+}
+
+func NewGithubFetcher(githubUser, githubAccessToken string, storage Backend, requestTimeout time.Duration) GithubFetcher {
 
 	tp := github.BasicAuthTransport{
 		Username: githubUser,
@@ -110,12 +146,80 @@ func (f GithubFetcher) totalCodeSizeLimitReached(ctx context.Context, language L
 	return false, nil
 }
 
-func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query string, maxTotalSizeBytes int) error {
+// fetchState counters and limits shared by the sub queries of FetchCodes, the summary is
+// updated by the download goroutines as well
+type fetchState struct {
+	summary FetchSummary
+	mutex   sync.Mutex
+	limiter *fetchLimiter
+}
+
+func (state *fetchState) count(counter *int) {
+	state.mutex.Lock()
+	*counter++
+	state.mutex.Unlock()
+}
+
+func (state *fetchState) skip(codeResult *github.CodeResult, reason string) {
+	log.Infof("Skip: %s - %s", codeResult.GetHTMLURL(), reason)
+	state.mutex.Lock()
+	state.summary.Skipped[reason]++
+	state.mutex.Unlock()
+}
+
+// FetchCodes searches code files of language matching query, see ParseSearchQuery, and stores
+// them. The extensions and file names of the query, or of the language if the query has none,
+// are searched one after another, the progress of each sub query is stored separately by its
+// search string. Progress of earlier versions, which searched the unsplit query, is not reused.
+func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query string, maxTotalSizeBytes int) (FetchSummary, error) {
+	state := &fetchState{
+		summary: FetchSummary{Skipped: make(map[string]int), Mismatches: make(map[string]int)},
+		limiter: newFetchLimiter(f.Limits, f.storage),
+	}
 
 	if len(query) == 0 {
-		return ErrorInvalidQuery
+		return state.summary, ErrorInvalidQuery
 	}
+	search, err := ParseLanguageSearchQuery(language, query)
+	if err != nil {
+		return state.summary, err
+	}
+
+	for _, sub := range search.SubQueries() {
+		limitReached, err := f.fetchQuery(ctx, state, language, sub.String(), maxTotalSizeBytes)
+		if err != nil || limitReached {
+			return state.summary, err
+		}
+	}
+	return state.summary, nil
+}
+
+// FetchQueries runs FetchCodes for each query in order, e.g. the queries of SeedQueries, and
+// stops once the total code size limit of the language is reached
+func (f GithubFetcher) FetchQueries(ctx context.Context, language Language, queries []string, maxTotalSizeBytes int) (FetchSummary, error) {
+	summary := FetchSummary{Skipped: make(map[string]int), Mismatches: make(map[string]int)}
+	for i, query := range queries {
+		totalSizeLimitReached, err := f.totalCodeSizeLimitReached(ctx, language, maxTotalSizeBytes)
+		if err != nil {
+			return summary, err
+		} else if totalSizeLimitReached {
+			log.Infof("Total code size limit for language %s reached after %d of %d queries", language.String(), i, len(queries))
+			return summary, nil
+		}
 
+		log.Infof("Status: Query %d of %d: %s", i+1, len(queries), query)
+		querySummary, err := f.FetchCodes(ctx, language, query, maxTotalSizeBytes)
+		summary = summary.Add(querySummary)
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
+
+// fetchQuery fetches all pages of a single search string, returns true if the total code size
+// limit of the language is reached
+func (f GithubFetcher) fetchQuery(ctx context.Context, state *fetchState, language Language, query string, maxTotalSizeBytes int) (bool, error) {
 	opt := &github.SearchOptions{
 		ListOptions: github.ListOptions{PerPage: 30},
 	}
@@ -126,7 +230,7 @@ func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query
 		log.Infof("Resuming from page %d", lastPage)
 		if opt.Page == -1 { // -1 indicates that the search is complete
 			log.Infof("Search for language %s and query %s is already complete", language.String(), query)
-			return nil
+			return false, nil
 		}
 	}
 	defer func() {
@@ -135,10 +239,11 @@ func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query
 		}
 	}()
 
+	log.Infof("Searching \"%s\"", query)
 	for {
 		time.Sleep(f.requestTimeout) // sleep to avoid rate limit
 		log.Infof("Fetching page %d with %d entries per page", opt.Page, opt.PerPage)
-		result, response, err := f.client.Search.Code(ctx, fmt.Sprintf("%s+%s", query, language.GithubQueryFilter()), opt)
+		result, response, err := f.client.Search.Code(ctx, query, opt)
 		if err != nil {
 			if _, ok := err.(*github.RateLimitError); ok {
 				log.Errorf("Rate limit error: %s", err.Error())
@@ -170,18 +275,26 @@ func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query
 					continue
 				}
 			}
-			return err
+			return false, err
 		}
 
 		// stop fetching code if total size limit is reached
 		totalSizeLimitReached, err := f.totalCodeSizeLimitReached(ctx, language, maxTotalSizeBytes)
 		if err != nil {
-			return err
+			return false, err
 		} else if totalSizeLimitReached {
 			log.Infof("Total code size limit for language %s reached: %d bytes", language.String(), maxTotalSizeBytes)
-			return nil
+			return true, nil
 		}
 
+		state.summary.Searched += len(result.CodeResults)
+		if result.GetTotal() == 0 && opt.Page <= 1 && !result.GetIncompleteResults() {
+			// sub queries of rare extensions or file names may have no results at all, empty
+			// later or incomplete pages are retried below
+			log.Infof("Status: No code files found for query %s", query)
+			opt.Page = -1
+			return false, nil
+		}
 		log.Infof("Status: Downloading %d new code files...", len(result.CodeResults))
 		if len(result.CodeResults) == 0 {
 			log.Errorf("No code files found for language %s and query %s", language.String(), query)
@@ -194,33 +307,105 @@ func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query
 		g, errCtx := errgroup.WithContext(ctx)
 		g.SetLimit(MaxRequestsParallel) // limit number of parallel requests, set to 1 to avoid github rate limit!
 		for _, codeResult := range result.CodeResults {
-			if err = language.ValidFileExtension(codeResult.GetPath()); err != nil {
-				log.Infof("Skip: %s - %s", codeResult.GetHTMLURL(), err.Error())
+			codeResult := codeResult
+			// files without extension are checked by their shebang after the download
+			if err = language.ValidFileExtension(codeResult.GetPath()); err != nil && !language.acceptsShebang(codeResult.GetPath()) {
+				state.skip(&codeResult, SkipReasonInvalidExtension)
+				continue
+			}
+			if !f.Paths.Allowed(language, codeResult.GetPath()) {
+				state.skip(&codeResult, SkipReasonExcludedPath)
 				continue
 			}
 
 			codeAlreadyExists, err := f.storage.CodeExistsByHash(ctx, codeResult.GetSHA())
 			if err == nil && codeAlreadyExists {
 				log.Infof("Skip: %s - Code already exists", codeResult.GetHTMLURL())
+				state.count(&state.summary.Duplicates)
+				continue
+			}
+
+			repository := Repository{
+				Owner: codeResult.GetRepository().GetOwner().GetLogin(),
+				Name:  codeResult.GetRepository().GetName(),
+			}
+			if _, blocked := f.Blocklist.Match(repository, codeResult.GetPath()); blocked {
+				state.skip(&codeResult, SkipReasonBlocklisted)
+				continue
+			}
+
+			tombstoned, err := f.storage.TombstoneExists(ctx, codeResult.GetSHA())
+			if err != nil {
+				g.Wait()
+				return false, err
+			} else if tombstoned {
+				state.skip(&codeResult, SkipReasonTombstoned)
+				continue
+			}
+
+			reason, err := state.limiter.reserve(ctx, repository)
+			if err != nil {
+				g.Wait()
+				return false, err
+			} else if len(reason) > 0 {
+				state.skip(&codeResult, reason)
 				continue
 			}
 
 			g.Go(func() error {
+				// files skipped after the download don't count towards the repository limit
+				stored := false
+				defer func() {
+					if !stored {
+						state.limiter.release(repository)
+					}
+				}()
+
 				time.Sleep(f.requestTimeout) // sleep to avoid rate limit
 				code, err := f.DownloadCode(errCtx, &codeResult)
 				if err != nil {
 					if err == ErrorCodeSizeLimitExceeded {
-						log.Infof("Skip: %s - %s", codeResult.GetHTMLURL(), err.Error())
+						state.skip(&codeResult, SkipReasonCodeSizeLimit)
 						return nil
 					}
 					log.Infof("Error downloading code: %s", err.Error())
 					return err
 				}
+				state.count(&state.summary.Downloaded)
+
+				if err := language.ValidFile(codeResult.GetPath(), code); err != nil {
+					state.skip(&codeResult, SkipReasonInvalidExtension)
+					return nil
+				}
+
+				language := language
+				if f.VerifyLanguage {
+					verified, mismatch, signal, ok := VerifyLanguage(language, codeResult.GetPath(), code)
+					if len(mismatch) > 0 {
+						log.Infof("Language mismatch: %s - %s detected by %s", codeResult.GetHTMLURL(), mismatch, signal)
+						state.mutex.Lock()
+						state.summary.Mismatches[LanguageMismatch(language, mismatch)]++
+						state.mutex.Unlock()
+					}
+					if !ok {
+						state.skip(&codeResult, SkipReasonLanguageMismatch)
+						return nil
+					}
+					language = verified
+				}
+
+				if name, ok := f.Filters.Check(language, codeResult.GetPath(), code); !ok {
+					state.skip(&codeResult, name)
+					return nil
+				}
 
 				err = f.storage.StoreCodefile(errCtx, language, codeResult.GetHTMLURL(), code, codeResult.GetSHA())
 				if err != nil {
 					return err
 				}
+				stored = true
+				state.limiter.stored(repository, len(code))
+				state.count(&state.summary.Stored)
 
 				log.Infof("OK: %s", codeResult.GetHTMLURL())
 				return nil
@@ -248,5 +433,5 @@ func (f GithubFetcher) FetchCodes(ctx context.Context, language Language, query
 		f.storage.UpdateProgress(ctx, language, query, opt.Page)
 	}
 
-	return nil
+	return false, nil
 }
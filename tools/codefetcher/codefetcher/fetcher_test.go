@@ -1,7 +1,16 @@
 package codefetcher
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	flag "github.com/spf13/pflag"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -38,3 +47,190 @@ func TestUnixEpochTime(t *testing.T) {
 	timestamp := time.Unix(time.Now().Unix(), 0)
 	t.Logf("%s", timestamp)
 }
+
+// testGithubFile a code file served by testGithubServer
+type testGithubFile struct {
+	Repository Repository
+	Path       string
+	Content    string
+}
+
+// testGithubServer fakes the code search and contents API of GitHub. search returns the files
+// of a page of a search string, pages up to lastPage link to the next one.
+type testGithubServer struct {
+	*httptest.Server
+	search   func(query string, page int) []testGithubFile
+	lastPage int
+	mu       sync.Mutex
+	requests []string // search strings along with their page, e.g. "print extension:py#1"
+	files    map[string]testGithubFile
+}
+
+func newTestGithubServer(t *testing.T, lastPage int, search func(query string, page int) []testGithubFile) *testGithubServer {
+	server := &testGithubServer{search: search, lastPage: lastPage, files: make(map[string]testGithubFile)}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// fetcher returns a fetcher of s sending its requests to the server
+func (server *testGithubServer) fetcher(s Backend) GithubFetcher {
+	f := NewGithubFetcher("", "", s, 0)
+	f.client.BaseURL, _ = url.Parse(server.URL + "/")
+	return f
+}
+
+func (server *testGithubServer) handle(w http.ResponseWriter, r *http.Request) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.URL.Path == "/search/code":
+		query := r.URL.Query().Get("q")
+		page := 1
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		server.requests = append(server.requests, fmt.Sprintf("%s#%d", query, page))
+
+		var items []map[string]any
+		for _, file := range server.search(query, page) {
+			key := file.Repository.String() + "/" + file.Path
+			server.files[key] = file
+			items = append(items, map[string]any{
+				"name":     path.Base(file.Path),
+				"path":     file.Path,
+				"sha":      GitBlobHash([]byte(file.Content)),
+				"html_url": file.Repository.URL() + "/blob/main/" + file.Path,
+				"repository": map[string]any{
+					"name":  file.Repository.Name,
+					"owner": map[string]any{"login": file.Repository.Owner},
+				},
+			})
+		}
+		if page < server.lastPage && len(items) > 0 {
+			next := *r.URL
+			values := next.Query()
+			values.Set("page", fmt.Sprint(page+1))
+			next.RawQuery = values.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, server.URL, next.RequestURI()))
+		}
+		total := len(items)
+		if page > 1 {
+			total += 30 * (page - 1)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"total_count": total, "incomplete_results": false, "items": items})
+	case strings.HasPrefix(r.URL.Path, "/repos/"):
+		// directory listing of repos/<owner>/<name>/contents/<dir>
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/repos/"), "/", 4)
+		repository, dir := parts[0]+"/"+parts[1], ""
+		if len(parts) == 4 {
+			dir = parts[3]
+		}
+		var entries []map[string]any
+		for key, file := range server.files {
+			if strings.HasPrefix(key, repository+"/") && path.Dir(file.Path) == path.Clean(dir) {
+				entries = append(entries, map[string]any{
+					"type":         "file",
+					"name":         path.Base(file.Path),
+					"path":         file.Path,
+					"download_url": server.URL + "/raw/" + key,
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(entries)
+	case strings.HasPrefix(r.URL.Path, "/raw/"):
+		file, ok := server.files[strings.TrimPrefix(r.URL.Path, "/raw/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(file.Content))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestFetchCodesReleasesRepositoryLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	tools := Repository{Owner: "alice", Name: "tools"}
+	server := newTestGithubServer(t, 2, func(query string, page int) []testGithubFile {
+		if page == 1 {
+			return []testGithubFile{{tools, "dist/min.py", "x=" + strings.Repeat("1+", 200) + "1\n"}}
+		}
+		return []testGithubFile{{tools, "src/main.py", "print('a')\n"}}
+	})
+
+	// the minified file is rejected after the download, its slot must not count
+	f := server.fetcher(s)
+	f.Limits = FetchLimits{MaxFilesPerRepository: 1}
+	f.Filters = Filters{MaxLineLengthFilter{Max: 100}}
+	summary, err := f.FetchCodes(ctx, testLanguage1, "print extension:py", 0)
+	if err != nil {
+		t.Fatalf("Error fetching codes: %v", err)
+	}
+	if summary.Stored != 1 || summary.Skipped[FilterMaxLineLength] != 1 || summary.Skipped[SkipReasonRepositoryLimit] != 0 {
+		t.Fatalf("Expected 1 stored and 1 filtered file, got %+v", summary)
+	}
+}
+
+func TestFetchCodesResumesQueryProgress(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	tools := Repository{Owner: "alice", Name: "tools"}
+	first := "print " + testLanguage1.GithubLanguageQuery() + " extension:py"
+	server := newTestGithubServer(t, 3, func(query string, page int) []testGithubFile {
+		if query != first {
+			return nil
+		}
+		return []testGithubFile{{tools, fmt.Sprintf("src/%d.py", page), fmt.Sprintf("print(%d)\n", page)}}
+	})
+
+	// the completed unsplit search of earlier versions had other results, it is not reused
+	if err := s.UpdateProgress(ctx, testLanguage1, "print", -1); err != nil {
+		t.Fatalf("Error updating progress: %v", err)
+	}
+	if err := s.UpdateProgress(ctx, testLanguage1, first, 2); err != nil {
+		t.Fatalf("Error updating progress: %v", err)
+	}
+	summary, err := server.fetcher(s).FetchCodes(ctx, testLanguage1, "print", 0)
+	if err != nil {
+		t.Fatalf("Error fetching codes: %v", err)
+	}
+	if summary.Stored != 2 {
+		t.Fatalf("Expected the files of pages 2 and 3, got %+v", summary)
+	}
+	if server.requests[0] != first+"#2" {
+		t.Fatalf("Expected the search to resume at page 2, got requests %v", server.requests)
+	}
+
+	progress, err := s.ListProgress(ctx)
+	if err != nil {
+		t.Fatalf("Error listing progress: %v", err)
+	}
+	queries := make(map[string]bool)
+	for _, p := range progress {
+		if p.LastPage != -1 {
+			t.Fatalf("Expected completed queries, got %+v", progress)
+		}
+		queries[p.Query] = true
+	}
+	for _, request := range server.requests {
+		query, _, _ := strings.Cut(request, "#")
+		if !queries[query] {
+			t.Fatalf("Expected the progress of sub query %q, got %+v", query, progress)
+		}
+	}
+	if len(queries) != len(server.requests) {
+		t.Fatalf("Expected the unsplit query and one progress per sub query, got %+v for requests %v", progress, server.requests)
+	}
+}
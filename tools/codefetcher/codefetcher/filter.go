@@ -0,0 +1,258 @@
+package codefetcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+// names of the built-in filters, counted as skip reasons in FetchSummary.Skipped and ImportReport.Skipped
+const (
+	FilterMinLines             = "min lines"
+	FilterMaxLines             = "max lines"
+	FilterMaxLineLength        = "max line length"
+	FilterMeanLineLength       = "mean line length"
+	FilterAlphanumericFraction = "alphanumeric fraction"
+	FilterNonASCIIRatio        = "non-ascii ratio"
+)
+
+// Filter decides whether a downloaded or imported code file is stored, path is the file path
+// within its repository
+type Filter interface {
+	Name() string // skip reason of rejected code files
+	Accept(language Language, path string, code []byte) bool
+}
+
+// Filters applied in order, the first filter rejecting a code file wins
+type Filters []Filter
+
+// Check returns the name of the first filter rejecting code, ok is true if all filters accept it
+func (fs Filters) Check(language Language, path string, code []byte) (name string, ok bool) {
+	for _, f := range fs {
+		if !f.Accept(language, path, code) {
+			return f.Name(), false
+		}
+	}
+	return "", true
+}
+
+// codeLines returns the number of lines of code along with the length of the longest line
+// and the total length of all lines in characters, line breaks excluded
+func codeLines(code []byte) (lines, maxLength, totalLength int) {
+	length := 0
+	for len(code) > 0 {
+		r, size := utf8.DecodeRune(code)
+		code = code[size:]
+		if r == '\n' {
+			lines++
+			maxLength = max(maxLength, length)
+			length = 0
+			continue
+		} else if r == '\r' {
+			continue
+		}
+		length++
+		totalLength++
+	}
+	if length > 0 { // last line without line break
+		lines++
+		maxLength = max(maxLength, length)
+	}
+	return lines, maxLength, totalLength
+}
+
+// MinLinesFilter rejects code files with less than Min lines, 0 to accept all
+type MinLinesFilter struct{ Min int }
+
+func (f MinLinesFilter) Name() string { return FilterMinLines }
+
+func (f MinLinesFilter) Accept(_ Language, _ string, code []byte) bool {
+	if f.Min <= 0 {
+		return true
+	}
+	lines, _, _ := codeLines(code)
+	return lines >= f.Min
+}
+
+// MaxLinesFilter rejects code files with more than Max lines, 0 to accept all
+type MaxLinesFilter struct{ Max int }
+
+func (f MaxLinesFilter) Name() string { return FilterMaxLines }
+
+func (f MaxLinesFilter) Accept(_ Language, _ string, code []byte) bool {
+	if f.Max <= 0 {
+		return true
+	}
+	lines, _, _ := codeLines(code)
+	return lines <= f.Max
+}
+
+// MaxLineLengthFilter rejects code files with a line longer than Max characters, e.g. minified
+// code or embedded data, 0 to accept all
+type MaxLineLengthFilter struct{ Max int }
+
+func (f MaxLineLengthFilter) Name() string { return FilterMaxLineLength }
+
+func (f MaxLineLengthFilter) Accept(_ Language, _ string, code []byte) bool {
+	if f.Max <= 0 {
+		return true
+	}
+	_, maxLength, _ := codeLines(code)
+	return maxLength <= f.Max
+}
+
+// MeanLineLengthFilter rejects code files with a mean line length above Max characters, 0 to accept all
+type MeanLineLengthFilter struct{ Max float64 }
+
+func (f MeanLineLengthFilter) Name() string { return FilterMeanLineLength }
+
+func (f MeanLineLengthFilter) Accept(_ Language, _ string, code []byte) bool {
+	if f.Max <= 0 {
+		return true
+	}
+	lines, _, totalLength := codeLines(code)
+	return lines == 0 || float64(totalLength)/float64(lines) <= f.Max
+}
+
+// AlphanumericFractionFilter rejects code files with less than Min of their characters being
+// letters or digits, e.g. data tables of numbers and punctuation, 0 to accept all
+type AlphanumericFractionFilter struct{ Min float64 }
+
+func (f AlphanumericFractionFilter) Name() string { return FilterAlphanumericFraction }
+
+func (f AlphanumericFractionFilter) Accept(_ Language, _ string, code []byte) bool {
+	if f.Min <= 0 {
+		return true
+	}
+	total, alphanumeric := 0, 0
+	for _, r := range string(code) {
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			alphanumeric++
+		}
+	}
+	return total == 0 || float64(alphanumeric)/float64(total) >= f.Min
+}
+
+// NonASCIIRatioFilter rejects code files with more than Max of their characters outside of
+// ASCII, 0 to accept all
+type NonASCIIRatioFilter struct{ Max float64 }
+
+func (f NonASCIIRatioFilter) Name() string { return FilterNonASCIIRatio }
+
+func (f NonASCIIRatioFilter) Accept(_ Language, _ string, code []byte) bool {
+	if f.Max <= 0 {
+		return true
+	}
+	total, nonASCII := 0, 0
+	for _, r := range string(code) {
+		total++
+		if r > unicode.MaxASCII {
+			nonASCII++
+		}
+	}
+	return total == 0 || float64(nonASCII)/float64(total) <= f.Max
+}
+
+// LanguageFilter applies the filter configured for the language of a code file, Default for
+// all other languages. The name is taken from Default.
+type LanguageFilter struct {
+	Default   Filter
+	Languages map[string]Filter // by Language.String()
+}
+
+func (f LanguageFilter) Name() string { return f.Default.Name() }
+
+func (f LanguageFilter) Accept(language Language, path string, code []byte) bool {
+	if filter, ok := f.Languages[language.String()]; ok {
+		return filter.Accept(language, path, code)
+	}
+	return f.Default.Accept(language, path, code)
+}
+
+// QualityThresholds thresholds of the built-in filters, 0 disables a filter
+type QualityThresholds struct {
+	MinLines                int     `json:"min_lines"`
+	MaxLines                int     `json:"max_lines"`
+	MaxLineLength           int     `json:"max_line_length"`
+	MaxMeanLineLength       float64 `json:"max_mean_line_length"`
+	MinAlphanumericFraction float64 `json:"min_alphanumeric_fraction"`
+	MaxNonASCIIRatio        float64 `json:"max_non_ascii_ratio"`
+}
+
+// DefaultQualityThresholds reject minified code and data files while keeping regular source code
+var DefaultQualityThresholds = QualityThresholds{
+	MaxLineLength:           1000,
+	MaxMeanLineLength:       100,
+	MinAlphanumericFraction: 0.25,
+}
+
+func (t QualityThresholds) filters() Filters {
+	return Filters{
+		MinLinesFilter{t.MinLines},
+		MaxLinesFilter{t.MaxLines},
+		MaxLineLengthFilter{t.MaxLineLength},
+		MeanLineLengthFilter{t.MaxMeanLineLength},
+		AlphanumericFractionFilter{t.MinAlphanumericFraction},
+		NonASCIIRatioFilter{t.MaxNonASCIIRatio},
+	}
+}
+
+// QualityConfig thresholds of the built-in filters with overrides per language
+type QualityConfig struct {
+	Default   QualityThresholds            `json:"default"`
+	Languages map[string]QualityThresholds `json:"languages"` // by Language.String()
+}
+
+// Filters returns the built-in filters, each one applying the thresholds of the code file's language
+func (c QualityConfig) Filters() Filters {
+	filters := c.Default.filters()
+	for language, thresholds := range c.Languages {
+		for i, filter := range thresholds.filters() {
+			languageFilter, ok := filters[i].(LanguageFilter)
+			if !ok {
+				languageFilter = LanguageFilter{Default: filters[i], Languages: make(map[string]Filter)}
+			}
+			languageFilter.Languages[language] = filter
+			filters[i] = languageFilter
+		}
+	}
+	return filters
+}
+
+// ReadQualityConfig reads a JSON quality config on top of defaults, e.g.
+//
+//	{"default": {"min_lines": 5}, "languages": {"javascript": {"max_line_length": 2000}}}
+//
+// Thresholds missing in the default object keep the value of defaults, thresholds missing in a
+// language object keep the resulting default value. Languages are given as understood by ParseLanguage.
+func ReadQualityConfig(r io.Reader, defaults QualityThresholds) (QualityConfig, error) {
+	var raw struct {
+		Default   json.RawMessage            `json:"default"`
+		Languages map[string]json.RawMessage `json:"languages"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return QualityConfig{}, err
+	}
+
+	config := QualityConfig{Default: defaults, Languages: make(map[string]QualityThresholds)}
+	if len(raw.Default) > 0 {
+		if err := json.Unmarshal(raw.Default, &config.Default); err != nil {
+			return QualityConfig{}, fmt.Errorf("default: %w", err)
+		}
+	}
+	for name, data := range raw.Languages {
+		language, err := ParseLanguage(name)
+		if err != nil {
+			return QualityConfig{}, err
+		}
+		thresholds := config.Default
+		if err = json.Unmarshal(data, &thresholds); err != nil {
+			return QualityConfig{}, fmt.Errorf("%s: %w", name, err)
+		}
+		config.Languages[language.String()] = thresholds
+	}
+	return config, nil
+}
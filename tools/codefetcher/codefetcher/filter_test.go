@@ -0,0 +1,99 @@
+package codefetcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCodeLines(t *testing.T) {
+	lines, maxLength, totalLength := codeLines([]byte("ab\r\nc\n\nüöä"))
+	if lines != 4 || maxLength != 3 || totalLength != 6 {
+		t.Fatalf("Expected 4 lines, max length 3 and total length 6, got %d, %d, %d", lines, maxLength, totalLength)
+	}
+}
+
+func TestQualityFilters(t *testing.T) {
+	javascript, _ := ParseLanguage("javascript")
+
+	tests := []struct {
+		code     string
+		language Language
+		expected string
+	}{
+		{string(testCodefileHelloWorld), testLanguage1, ""},
+		{"// minified\nvar a=" + strings.Repeat("1,", 600) + "0;\n", javascript, FilterMaxLineLength},
+		{strings.Repeat(strings.Repeat("x", 150)+"\n", 3), testLanguage1, FilterMeanLineLength},
+		{strings.Repeat("0, 1, 2, -3, [4], 5,\n", 10), testLanguage1, ""},
+		{strings.Repeat("[-], [-], [-], (.)\n", 10), testLanguage1, FilterAlphanumericFraction},
+		{"print(1)\n", testLanguage1, FilterMinLines},
+		{strings.Repeat("print(1)\n", 11), testLanguage1, FilterMaxLines},
+		{"# äöüßäöüß\nprint(1)\n", testLanguage1, FilterNonASCIIRatio},
+	}
+
+	filters := QualityConfig{Default: QualityThresholds{
+		MinLines:                2,
+		MaxLines:                10,
+		MaxLineLength:           1000,
+		MaxMeanLineLength:       100,
+		MinAlphanumericFraction: 0.25,
+		MaxNonASCIIRatio:        0.2,
+	}}.Filters()
+	for i, test := range tests {
+		name, ok := filters.Check(test.language, "main", []byte(test.code))
+		if name != test.expected || ok != (len(test.expected) == 0) {
+			t.Errorf("Expected %q for test %d, got %q", test.expected, i, name)
+		}
+	}
+}
+
+func TestReadQualityConfig(t *testing.T) {
+	config, err := ReadQualityConfig(strings.NewReader(`{"default": {"min_lines": 2}, "languages": {"js": {"max_line_length": 2000}}}`), DefaultQualityThresholds)
+	if err != nil {
+		t.Fatalf("Error reading quality config: %v", err)
+	}
+	expected := DefaultQualityThresholds
+	expected.MinLines = 2
+	if config.Default != expected {
+		t.Fatalf("Expected default thresholds %+v, got %+v", expected, config.Default)
+	}
+	expected.MaxLineLength = 2000
+	if config.Languages["JavaScript"] != expected {
+		t.Fatalf("Expected JavaScript thresholds %+v, got %+v", expected, config.Languages["JavaScript"])
+	}
+
+	// the longer lines are only accepted for JavaScript
+	javascript, _ := ParseLanguage("js")
+	code := []byte(strings.Repeat("a", 1500) + "\n" + strings.Repeat("b\n", 20))
+	filters := config.Filters()
+	if name, ok := filters.Check(javascript, "main.js", code); !ok {
+		t.Fatalf("Expected JavaScript code to be accepted, got %q", name)
+	}
+	if name, _ := filters.Check(testLanguage1, "main.py", code); name != FilterMaxLineLength {
+		t.Fatalf("Expected %q, got %q", FilterMaxLineLength, name)
+	}
+
+	if _, err = ReadQualityConfig(strings.NewReader(`{"languages": {"cobol": {}}}`), DefaultQualityThresholds); err == nil {
+		t.Fatalf("Expected error for unknown language")
+	}
+}
+
+func TestImportFilters(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(testImportJSONL)), ImportOptions{
+		Source:  "test-dataset",
+		Fields:  ImportFieldMapping{Content: "text", Language: "language", Repo: "repository"},
+		Filters: Filters{MaxLineLengthFilter{Max: 9}},
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if report.Skipped[FilterMaxLineLength] != 2 {
+		t.Fatalf("Expected 2 files skipped by %q, got %v", FilterMaxLineLength, report.Skipped)
+	}
+}
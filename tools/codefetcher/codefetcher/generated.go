@@ -0,0 +1,157 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	log "github.com/sirupsen/logrus"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	sqlUpdateGenerated = `UPDATE code SET generated = ? WHERE id = ?;`
+	sqlUntagged        = `generated IS NULL`
+	sqlNotGenerated    = `IFNULL(generated, 0) = 0`
+)
+
+// FilterGenerated name of GeneratedFilter, counted as skip reason
+const FilterGenerated = "generated"
+
+// generatedHeaderLines number of leading lines searched for header markers
+const generatedHeaderLines = 30
+
+// generatedHeaderMarkers comments code generators put at the top of their output, by name
+var generatedHeaderMarkers = []struct {
+	name    string
+	pattern *regexp.Regexp
+}{
+	{"do not edit", regexp.MustCompile(`(?i)code generated .*do not edit`)},
+	{"auto-generated", regexp.MustCompile(`(?i)<auto-?generated`)},
+	{"@generated", regexp.MustCompile(`@generated\b`)},
+	{"protoc", regexp.MustCompile(`(?i)generated by the protocol buffer compiler`)},
+	{"antlr", regexp.MustCompile(`(?i)generated from \S+ by antlr`)},
+	{"generated", regexp.MustCompile(`(?i)((this|the following) (file|code|source) (is|was|has been) (automatically |auto-?)?generated|auto-?generated (file|code|by))`)},
+}
+
+// generatedFileNames base names of files written by code generators, matched case-insensitively
+var generatedFileNames = []string{
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*.pb.cc",
+	"*.pb.h",
+	"*.pb.c",
+	"*.designer.cs",
+	"*.g.cs",
+	"*.g.i.cs",
+	"*.generated.cs",
+	"*_generated.go",
+	"zz_generated*.go",
+}
+
+// generatedStructures code that is only written by generators, searched in the whole file
+var generatedStructures = [][]byte{
+	[]byte("_serializedATN"),                               // ANTLR lexers and parsers
+	[]byte("_descriptor_pool.Default().AddSerializedFile"), // Python protobuf
+	[]byte("_descriptor.FileDescriptor("),                  // Python protobuf, older versions
+	[]byte("protoimpl.UnsafeEnabled"),                      // Go protobuf
+	[]byte("System.CodeDom.Compiler.GeneratedCode"),        // C# designer and tool output
+	[]byte("@javax.annotation.Generated"),                  // Java annotation processors
+	[]byte("@javax.annotation.processing.Generated"),
+	[]byte("com.google.protobuf.GeneratedMessageV3"), // Java protobuf
+}
+
+// DetectGenerated returns the signal identifying code as generated, e.g. a header marker,
+// empty if the file looks hand written. name is the file name or path.
+func DetectGenerated(name string, code []byte) string {
+	base := strings.ToLower(path.Base(name))
+	for _, pattern := range generatedFileNames {
+		if MatchPathGlob(pattern, base) {
+			return "file name " + pattern
+		}
+	}
+
+	header := code
+	for i, n := 0, 0; i < len(code); i++ {
+		if code[i] == '\n' {
+			if n++; n == generatedHeaderLines {
+				header = code[:i]
+				break
+			}
+		}
+	}
+	for _, marker := range generatedHeaderMarkers {
+		if marker.pattern.Match(header) {
+			return "header " + marker.name
+		}
+	}
+
+	for _, structure := range generatedStructures {
+		if bytes.Contains(code, structure) {
+			return "structure " + string(structure)
+		}
+	}
+	return ""
+}
+
+// GeneratedFilter rejects generated code files, see DetectGenerated
+type GeneratedFilter struct{}
+
+func (f GeneratedFilter) Name() string { return FilterGenerated }
+
+func (f GeneratedFilter) Accept(_ Language, path string, code []byte) bool {
+	return len(DetectGenerated(path, code)) == 0
+}
+
+// GeneratedReport result of Storage.TagGenerated, generated files by language
+type GeneratedReport struct {
+	Checked   int            `json:"checked"`
+	Generated int            `json:"generated"`
+	Languages map[string]int `json:"languages"`
+}
+
+// TagGenerated runs DetectGenerated on stored code files and tags the generated ones. New files
+// are tagged when stored, so only files without a tag are checked unless all is set, which is
+// needed after the detection changed.
+func (s Storage) TagGenerated(ctx context.Context, all bool) (GeneratedReport, error) {
+	report := GeneratedReport{Languages: make(map[string]int)}
+	if s.DB == nil {
+		return report, ErrorNoDatabase
+	}
+
+	var cursor int64
+	for {
+		codefiles, next, err := s.ListCodefiles(ctx, CodefileFilter{untagged: !all}, cursor, defaultIterateBatchSize)
+		if err != nil {
+			return report, err
+		}
+
+		tx, err := s.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return report, err
+		}
+		for _, c := range codefiles {
+			generated := len(DetectGenerated(c.URL, c.Content)) > 0
+			if _, err = tx.ExecContext(ctx, sqlUpdateGenerated, generated, c.ID); err != nil {
+				tx.Rollback()
+				log.Debugf("Failed to tag codefile %d: %s", c.ID, err.Error())
+				return report, err
+			}
+			report.Checked++
+			if generated {
+				report.Generated++
+				report.Languages[c.Language]++
+			}
+		}
+		if err = tx.Commit(); err != nil {
+			return report, err
+		}
+
+		if next == 0 {
+			return report, nil
+		}
+		cursor = next
+	}
+}
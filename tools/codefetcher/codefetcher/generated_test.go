@@ -0,0 +1,83 @@
+package codefetcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDetectGenerated(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		generated bool
+	}{
+		{"main.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\npackage api\n", true},
+		{"Form1.cs", "//------\n// <auto-generated>\n//     This code was generated by a tool.\n", true},
+		{"schema.js", "/**\n * @generated SignedSource<<123>>\n */\n", true},
+		{"GoParser.java", "// Generated from GoParser.g4 by ANTLR 4.13.1\npackage com.codetokenizer;\n", true},
+		{"api_pb2.py", "import sys\n", true},
+		{"Form1.Designer.cs", "namespace App {}\n", true},
+		{"lexer.py", "import sys\n" + strings.Repeat("\n", 50) + "    _serializedATN = [4,1,2]\n", true},
+		{"main.py", string(testCodefileHelloWorld), false},
+		{"ids.py", "# ids are generated by the database\nprint(1)\n", false},
+		{"late.go", strings.Repeat("//\n", 40) + "// Code generated by hand. DO NOT EDIT.\n", false},
+	}
+	for _, test := range tests {
+		if signal := DetectGenerated("https://github.com/alice/tools/blob/HEAD/src/"+test.name, []byte(test.code)); (len(signal) > 0) != test.generated {
+			t.Errorf("Expected generated %v for %s, got %q", test.generated, test.name, signal)
+		}
+	}
+}
+
+func TestTagGenerated(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	err := s.StoreCodefile(ctx, testLanguage1, "http://localhost/api_pb2.py", []byte("import sys\n"), "g1")
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+	err = s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	// new files are tagged when stored
+	c, err := s.GetCodefileByHash(ctx, "g1")
+	if err != nil {
+		t.Fatalf("Error getting codefile: %v", err)
+	}
+	if !c.Generated {
+		t.Fatalf("Expected codefile to be tagged as generated")
+	}
+	codefiles, _, err := s.ListCodefiles(ctx, CodefileFilter{SkipGenerated: true}, 0, 0)
+	if err != nil {
+		t.Fatalf("Error listing codefiles: %v", err)
+	}
+	if len(codefiles) != 1 || codefiles[0].Hash != testCodefileHelloWorldHash {
+		t.Fatalf("Expected only the hand written codefile, got %+v", codefiles)
+	}
+
+	// files stored before the generated column existed
+	if _, err = s.DB.ExecContext(ctx, `UPDATE code SET generated = NULL WHERE hash = ?;`, "g1"); err != nil {
+		t.Fatalf("Error resetting tag: %v", err)
+	}
+	report, err := s.TagGenerated(ctx, false)
+	if err != nil {
+		t.Fatalf("Error tagging generated codefiles: %v", err)
+	}
+	if report.Checked != 1 || report.Languages[testLanguage1.String()] != 1 {
+		t.Fatalf("Expected 1 checked generated codefile, got %+v", report)
+	}
+
+	if report, err = s.TagGenerated(ctx, true); err != nil {
+		t.Fatalf("Error tagging generated codefiles: %v", err)
+	}
+	if report.Checked != 2 || report.Generated != 1 {
+		t.Fatalf("Expected 2 checked codefiles and 1 generated, got %+v", report)
+	}
+}
@@ -0,0 +1,332 @@
+package codefetcher
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/parquet-go/parquet-go"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"strings"
+)
+
+// DefaultImportBatchSize number of rows per import transaction
+const DefaultImportBatchSize = 1000
+
+// skip reasons, counted in ImportReport.Skipped and FetchSummary.Skipped
+const (
+	SkipReasonMissingContent   = "missing content"
+	SkipReasonUnknownLanguage  = "unknown language"
+	SkipReasonLanguageFiltered = "language not selected"
+	SkipReasonInvalidExtension = "invalid extension"
+	SkipReasonCodeSizeLimit    = "code size limit"
+	SkipReasonTotalSizeLimit   = "total size limit"
+)
+
+// ImportFieldMapping names of the dataset fields holding the file attributes
+type ImportFieldMapping struct {
+	Content  string
+	Language string
+	Path     string
+	Repo     string
+}
+
+// DefaultImportFieldMapping field names used by most public code datasets
+var DefaultImportFieldMapping = ImportFieldMapping{
+	Content:  "content",
+	Language: "lang",
+	Path:     "path",
+	Repo:     "repo",
+}
+
+// ImportOptions parameters of Storage.Import
+type ImportOptions struct {
+	Source          string             // recorded in every imported row, e.g. the dataset name
+	Fields          ImportFieldMapping // empty fields fall back to DefaultImportFieldMapping
+	LanguageMapping map[string]string  // dataset language name to a name understood by ParseLanguage
+	Languages       []Language         // only import these languages, all if empty
+	MaxTotalSize    int                // maximum total code size per language in bytes, 0 for no limit
+	Filters         Filters            // rejected rows are counted by filter name
+	Paths           PathRules
+	Blocklist       Blocklist // rows of blocked owners, repositories and paths are skipped
+	VerifyLanguage  bool      // check the language of every row with VerifyLanguage, see ImportReport.Mismatches
+	BatchSize       int
+}
+
+// ImportReport result of an import, code file counts by language and skipped rows by reason
+type ImportReport struct {
+	MergeReport
+	Read       int            `json:"read"`
+	Skipped    map[string]int `json:"skipped"`
+	Mismatches map[string]int `json:"mismatches"` // rows with a language detected by VerifyLanguage, see LanguageMismatch
+}
+
+// ImportRecordReader reads the rows of a dataset, Next returns io.EOF after the last row
+type ImportRecordReader interface {
+	Next() (map[string]string, error)
+}
+
+// JSONLRecordReader reads one JSON object per line
+type JSONLRecordReader struct {
+	decoder *json.Decoder
+}
+
+func NewJSONLRecordReader(r io.Reader) *JSONLRecordReader {
+	return &JSONLRecordReader{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+func (r *JSONLRecordReader) Next() (map[string]string, error) {
+	var object map[string]any
+	if err := r.decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]string, len(object))
+	for key, value := range object {
+		switch v := value.(type) {
+		case string:
+			record[key] = v
+		case nil:
+		default:
+			record[key] = fmt.Sprint(v)
+		}
+	}
+	return record, nil
+}
+
+// ParquetRecordReader reads the top-level columns of a Parquet file
+type ParquetRecordReader struct {
+	reader  *parquet.Reader
+	columns map[int]string
+	rows    []parquet.Row
+}
+
+func NewParquetRecordReader(r io.ReaderAt, size int64) (*ParquetRecordReader, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[int]string)
+	for _, field := range file.Schema().Fields() {
+		if leaf, ok := file.Schema().Lookup(field.Name()); ok && field.Leaf() {
+			columns[leaf.ColumnIndex] = field.Name()
+		}
+	}
+	return &ParquetRecordReader{reader: parquet.NewReader(file), columns: columns, rows: make([]parquet.Row, 1)}, nil
+}
+
+func (r *ParquetRecordReader) Next() (map[string]string, error) {
+	n, err := r.reader.ReadRows(r.rows)
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+
+	record := make(map[string]string, len(r.columns))
+	for _, value := range r.rows[0] {
+		name, ok := r.columns[value.Column()]
+		if !ok || value.IsNull() {
+			continue
+		}
+		record[name] = value.String()
+	}
+	return record, nil
+}
+
+// GitBlobHash returns the git object id of content, the same hash GitHub reports for a file
+func GitBlobHash(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// importRepository parses the repository of an imported file, false if it isn't in "owner/name" notation
+func importRepository(repo string) (Repository, bool) {
+	repo = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(repo, "https://"), "github.com/"), "/")
+	if parts := strings.Split(repo, "/"); len(parts) == 2 && len(parts[0]) > 0 && len(parts[1]) > 0 {
+		return Repository{Owner: parts[0], Name: parts[1]}, true
+	}
+	return Repository{}, false
+}
+
+// importURL builds the url of an imported file, repositories in "owner/name" notation get a GitHub url
+func importURL(source, repo, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if repository, ok := importRepository(repo); ok {
+		return fmt.Sprintf("https://github.com/%s/blob/HEAD/%s", repository, path)
+	}
+	repo = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(repo, "https://"), "github.com/"), "/")
+	return fmt.Sprintf("%s:%s/%s", source, repo, path)
+}
+
+// Import stores the rows of a code dataset, applying the same checks as GithubFetcher.FetchCodes:
+// the file extension must match the language, excluded paths, blocklisted files, files above
+// CodeSizeLimit and files rejected by one of the filters are skipped and duplicates are detected
+// by hash. Rows are inserted in transactions of BatchSize rows.
+func (s Storage) Import(ctx context.Context, reader ImportRecordReader, options ImportOptions) (ImportReport, error) {
+	report := ImportReport{
+		MergeReport: MergeReport{Languages: make(map[string]*MergeCount)},
+		Skipped:     make(map[string]int),
+		Mismatches:  make(map[string]int),
+	}
+	if s.DB == nil {
+		return report, ErrorNoDatabase
+	}
+
+	fields := options.Fields
+	if len(fields.Content) == 0 {
+		fields.Content = DefaultImportFieldMapping.Content
+	}
+	if len(fields.Language) == 0 {
+		fields.Language = DefaultImportFieldMapping.Language
+	}
+	if len(fields.Path) == 0 {
+		fields.Path = DefaultImportFieldMapping.Path
+	}
+	if len(fields.Repo) == 0 {
+		fields.Repo = DefaultImportFieldMapping.Repo
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultImportBatchSize
+	}
+
+	selected := make(map[string]bool)
+	for _, language := range options.Languages {
+		selected[language.String()] = true
+	}
+
+	// languages are parsed once per dataset name, total sizes once per language
+	languages := make(map[string]*Language)
+	totalSizes := make(map[string]int)
+
+	var batch []Codefile
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.mergeCodefiles(ctx, batch, report.MergeReport)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return report, err
+		}
+		report.Read++
+
+		content, ok := record[fields.Content]
+		if !ok || len(content) == 0 {
+			report.Skipped[SkipReasonMissingContent]++
+			continue
+		}
+
+		name := record[fields.Language]
+		if mapped, ok := options.LanguageMapping[name]; ok {
+			name = mapped
+		}
+		language, ok := languages[name]
+		if !ok {
+			if l, err := ParseLanguage(name); err == nil {
+				language = &l
+			}
+			languages[name] = language
+		}
+		if language == nil {
+			report.Skipped[SkipReasonUnknownLanguage]++
+			continue
+		}
+		if len(selected) > 0 && !selected[language.String()] {
+			report.Skipped[SkipReasonLanguageFiltered]++
+			continue
+		}
+
+		path := record[fields.Path]
+		if err = language.ValidFile(path, []byte(content)); err != nil {
+			report.Skipped[SkipReasonInvalidExtension]++
+			continue
+		}
+		if !options.Paths.Allowed(*language, path) {
+			report.Skipped[SkipReasonExcludedPath]++
+			continue
+		}
+		// rows of other repositories are only checked against path: entries
+		repository, _ := importRepository(record[fields.Repo])
+		if _, blocked := options.Blocklist.Match(repository, path); blocked {
+			report.Skipped[SkipReasonBlocklisted]++
+			continue
+		}
+
+		if CodeSizeLimit > 0 && len(content) > CodeSizeLimit {
+			report.Skipped[SkipReasonCodeSizeLimit]++
+			continue
+		}
+
+		if options.VerifyLanguage {
+			verified, mismatch, signal, ok := VerifyLanguage(*language, path, []byte(content))
+			if len(mismatch) > 0 {
+				log.Debugf("Language mismatch: %s/%s - %s detected by %s", record[fields.Repo], path, mismatch, signal)
+				report.Mismatches[LanguageMismatch(*language, mismatch)]++
+			}
+			if !ok {
+				report.Skipped[SkipReasonLanguageMismatch]++
+				continue
+			}
+			if len(selected) > 0 && !selected[verified.String()] {
+				report.Skipped[SkipReasonLanguageFiltered]++
+				continue
+			}
+			language = &verified
+		}
+
+		if name, ok := options.Filters.Check(*language, path, []byte(content)); !ok {
+			report.Skipped[name]++
+			continue
+		}
+
+		if options.MaxTotalSize > 0 {
+			totalSize, ok := totalSizes[language.String()]
+			if !ok {
+				if totalSize, err = s.GetTotalCodeSizeByLanguage(ctx, *language); err != nil {
+					return report, err
+				}
+			}
+			if totalSize >= options.MaxTotalSize {
+				report.Skipped[SkipReasonTotalSizeLimit]++
+				continue
+			}
+			// duplicates are counted as well, which keeps the limit on the safe side
+			totalSizes[language.String()] = totalSize + len(content)
+		}
+
+		batch = append(batch, Codefile{
+			Language: language.String(),
+			URL:      importURL(options.Source, record[fields.Repo], path),
+			Content:  []byte(content),
+			Hash:     GitBlobHash([]byte(content)),
+			Size:     len(content),
+			Source:   options.Source,
+		})
+		if len(batch) >= options.BatchSize {
+			if err = flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, flush()
+}
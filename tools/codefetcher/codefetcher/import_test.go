@@ -0,0 +1,181 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	"github.com/parquet-go/parquet-go"
+	"strings"
+	"testing"
+)
+
+const testImportJSONL = `{"text": "print('a')\n", "language": "Python", "path": "src/a.py", "repository": "alice/tools", "stars": 3}
+{"text": "print('a')\n", "language": "Python", "path": "src/copy_of_a.py", "repository": "bob/tools"}
+{"text": "class A {}\n", "language": "C-Sharp", "path": "A.cs", "repository": "alice/cs"}
+{"text": "STOP RUN.\n", "language": "COBOL", "path": "main.cob", "repository": "alice/cobol"}
+{"text": "print('b')\n", "language": "Python", "path": "b.txt", "repository": "alice/tools"}
+{"text": "", "language": "Python", "path": "empty.py", "repository": "alice/tools"}
+`
+
+func TestGitBlobHash(t *testing.T) {
+	// git hash-object of "hello\n"
+	expected := "ce013625030ba8dba906f756967f9e9ca394464a"
+	if hash := GitBlobHash([]byte("hello\n")); hash != expected {
+		t.Fatalf("Expected hash %s, got %s", expected, hash)
+	}
+}
+
+func TestImportJSONL(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(testImportJSONL)), ImportOptions{
+		Source:          "test-dataset",
+		Fields:          ImportFieldMapping{Content: "text", Language: "language", Repo: "repository"},
+		LanguageMapping: map[string]string{"C-Sharp": "csharp"},
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+
+	if report.Read != 6 {
+		t.Errorf("Expected 6 read rows, got %d", report.Read)
+	}
+	python := report.Languages[testLanguage1.String()]
+	if python == nil || python.Inserted != 1 || python.Duplicates != 1 {
+		t.Errorf("Expected 1 inserted and 1 duplicate %s file, got %+v", testLanguage1, python)
+	}
+	csharp := report.Languages[testLanguage2.String()]
+	if csharp == nil || csharp.Inserted != 1 {
+		t.Errorf("Expected 1 inserted %s file, got %+v", testLanguage2, csharp)
+	}
+	for _, reason := range []string{SkipReasonUnknownLanguage, SkipReasonInvalidExtension, SkipReasonMissingContent} {
+		if report.Skipped[reason] != 1 {
+			t.Errorf("Expected 1 row skipped by %s, got %d", reason, report.Skipped[reason])
+		}
+	}
+
+	c, err := s.GetCodefileByHash(ctx, GitBlobHash([]byte("print('a')\n")))
+	if err != nil {
+		t.Fatalf("Error getting codefile: %v", err)
+	}
+	if c.Source != "test-dataset" || c.URL != "https://github.com/alice/tools/blob/HEAD/src/a.py" {
+		t.Fatalf("Unexpected codefile %+v", c)
+	}
+}
+
+func TestImportTotalSizeLimit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(testImportJSONL)), ImportOptions{
+		Fields:          ImportFieldMapping{Content: "text", Language: "language", Repo: "repository"},
+		LanguageMapping: map[string]string{"C-Sharp": "csharp"},
+		Languages:       []Language{testLanguage1},
+		MaxTotalSize:    1,
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if report.Skipped[SkipReasonTotalSizeLimit] != 1 || report.Skipped[SkipReasonLanguageFiltered] != 1 {
+		t.Fatalf("Expected 1 row skipped by total size limit and 1 by language, got %v", report.Skipped)
+	}
+}
+
+func TestImportBlocklist(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(testImportJSONL)), ImportOptions{
+		Fields:          ImportFieldMapping{Content: "text", Language: "language", Repo: "repository"},
+		LanguageMapping: map[string]string{"C-Sharp": "csharp"},
+		Blocklist:       Blocklist{{Kind: BlockOwner, Pattern: "bob"}, {Kind: BlockPath, Pattern: "*.cs"}},
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if report.Skipped[SkipReasonBlocklisted] != 2 {
+		t.Fatalf("Expected 2 rows skipped by %s, got %v", SkipReasonBlocklisted, report.Skipped)
+	}
+	python := report.Languages[testLanguage1.String()]
+	if python == nil || python.Inserted != 1 || python.Duplicates != 0 || report.Languages[testLanguage2.String()] != nil {
+		t.Fatalf("Expected only 1 inserted %s file, got %+v", testLanguage1, report.Languages)
+	}
+}
+
+func TestImportShebang(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	const dataset = `{"text": "#!/usr/bin/env python3\nprint('a')\n", "language": "Python", "path": "bin/run"}
+{"text": "print('b')\n", "language": "Python", "path": "bin/run"}
+{"text": "env = Environment()\n", "language": "Python", "path": "SConstruct"}
+`
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(dataset)), ImportOptions{
+		Fields: ImportFieldMapping{Content: "text", Language: "language"},
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if python := report.Languages[testLanguage1.String()]; python == nil || python.Inserted != 2 {
+		t.Fatalf("Expected 2 inserted %s files, got %+v", testLanguage1, python)
+	}
+	if report.Skipped[SkipReasonInvalidExtension] != 1 {
+		t.Fatalf("Expected 1 row skipped by %s, got %v", SkipReasonInvalidExtension, report.Skipped)
+	}
+}
+
+func TestImportParquet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	type row struct {
+		Content string `parquet:"content"`
+		Lang    string `parquet:"lang"`
+		Path    string `parquet:"path"`
+		Repo    string `parquet:"repo"`
+		Stars   int64  `parquet:"stars"`
+	}
+	var buffer bytes.Buffer
+	err := parquet.Write(&buffer, []row{
+		{"print('a')\n", "python", "a.py", "alice/tools", 1},
+		{"class A {}\n", "c#", "A.cs", "alice/cs", 2},
+	})
+	if err != nil {
+		t.Fatalf("Error writing parquet: %v", err)
+	}
+
+	reader, err := NewParquetRecordReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatalf("Error reading parquet: %v", err)
+	}
+	report, err := s.Import(ctx, reader, ImportOptions{Source: "parquet"})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if report.Read != 2 || report.Languages[testLanguage1.String()].Inserted != 1 || report.Languages[testLanguage2.String()].Inserted != 1 {
+		t.Fatalf("Expected 2 imported files, got %+v", report)
+	}
+
+	c, err := s.GetCodefileByHash(ctx, GitBlobHash([]byte("class A {}\n")))
+	if err != nil {
+		t.Fatalf("Error getting codefile: %v", err)
+	}
+	if c.Language != testLanguage2.String() || c.URL != "https://github.com/alice/cs/blob/HEAD/A.cs" {
+		t.Fatalf("Unexpected codefile %+v", c)
+	}
+}
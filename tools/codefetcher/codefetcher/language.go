@@ -1,13 +1,18 @@
 package codefetcher
 
 import (
+	"errors"
 	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"path"
+	"sort"
 	"strings"
 )
 
 type githubLanguage string
-type githubLanguages []githubLanguage
 
+// names of built-in languages with special handling, see DefaultLanguageDefinitions
 const (
 	githubLanguagePython     githubLanguage = "Python"
 	githubLanguageGolang                    = "Go"
@@ -19,57 +24,182 @@ const (
 	githubLanguageKotlin                    = "Kotlin"
 )
 
-var AvailableLanguages = githubLanguages{
-	githubLanguagePython,
-	githubLanguageGolang,
-	githubLanguageCSharp,
-	githubLanguageCpp,
-	githubLanguageC,
-	githubLanguageJava,
-	githubLanguageJavascript,
-	githubLanguageKotlin,
+var (
+	ErrorInvalidLanguageDefinition = errors.New("invalid language definition")
+)
+
+// LanguageDefinition entry of a LanguageRegistry
+type LanguageDefinition struct {
+	Name         string   `json:"name" yaml:"name"`                                     // GitHub name of the language, e.g. "C#"
+	Aliases      []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`           // further names accepted by ParseLanguage, case-insensitive
+	Extensions   []string `json:"extensions,omitempty" yaml:"extensions,omitempty"`     // file extensions, e.g. ".py"
+	Filenames    []string `json:"filenames,omitempty" yaml:"filenames,omitempty"`       // exact file names, e.g. "Makefile"
+	Interpreters []string `json:"interpreters,omitempty" yaml:"interpreters,omitempty"` // shebang interpreters without version, e.g. "python"
+	Query        string   `json:"query,omitempty" yaml:"query,omitempty"`               // GitHub search qualifier, "language:<name>" if empty
 }
 
-func (ls githubLanguages) String() string {
-	var s []string
-	for _, l := range AvailableLanguages {
-		s = append(s, string(l))
+// DefaultLanguageDefinitions built-in languages, GitHub Linguist's catalogue with the local
+// overrides of languages/overrides.yml
+var DefaultLanguageDefinitions = defaultLanguageDefinitions()
+
+// LanguageRegistry languages known to ParseLanguage, looked up by name or alias
+type LanguageRegistry struct {
+	languages    []Language
+	names        map[string]int // lowercase names and aliases
+	interpreters map[string]int
+}
+
+// NewLanguageRegistry returns a registry of definitions. Names and aliases must be unique,
+// every language needs at least one extension or file name.
+func NewLanguageRegistry(definitions []LanguageDefinition) (*LanguageRegistry, error) {
+	r := &LanguageRegistry{names: make(map[string]int), interpreters: make(map[string]int)}
+	for _, definition := range definitions {
+		if len(strings.TrimSpace(definition.Name)) == 0 {
+			return nil, fmt.Errorf("%w: missing name", ErrorInvalidLanguageDefinition)
+		}
+		if len(definition.Extensions) == 0 && len(definition.Filenames) == 0 {
+			return nil, fmt.Errorf("%w: %s has neither extensions nor file names", ErrorInvalidLanguageDefinition, definition.Name)
+		}
+
+		index := len(r.languages)
+		for _, name := range append([]string{definition.Name}, definition.Aliases...) {
+			key := strings.ToLower(name)
+			if other, ok := r.names[key]; ok && other != index {
+				return nil, fmt.Errorf("%w: %s of %s is already used by %s", ErrorInvalidLanguageDefinition, name, definition.Name, r.languages[other])
+			}
+			r.names[key] = index
+		}
+		for _, interpreter := range definition.Interpreters {
+			if _, ok := r.interpreters[interpreter]; !ok {
+				r.interpreters[interpreter] = index
+			}
+		}
+		r.languages = append(r.languages, newLanguage(definition))
+	}
+	return r, nil
+}
+
+// Parse returns the language of a name or alias
+func (r *LanguageRegistry) Parse(name string) (Language, error) {
+	if index, ok := r.names[strings.ToLower(name)]; ok {
+		return r.languages[index], nil
+	}
+	return Language{}, fmt.Errorf("unknown language: %s", name)
+}
+
+// Languages returns all languages in the order of their definitions
+func (r *LanguageRegistry) Languages() []Language {
+	return append([]Language(nil), r.languages...)
+}
+
+// interpreter returns the language of a shebang interpreter without version
+func (r *LanguageRegistry) interpreter(interpreter string) (Language, bool) {
+	index, ok := r.interpreters[interpreter]
+	if !ok {
+		return Language{}, false
+	}
+	return r.languages[index], true
+}
+
+// languageConfig file read by ReadLanguageDefinitions
+type languageConfig struct {
+	Languages []LanguageDefinition `json:"languages" yaml:"languages"`
+}
+
+// ReadLanguageDefinitions reads language definitions from YAML or JSON, e.g.
+//
+//	languages:
+//	  - name: Rust
+//	    aliases: [rs]
+//	    extensions: [.rs]
+//
+// The definitions are merged with defaults: a definition replaces the default of the same
+// name, other definitions are added.
+func ReadLanguageDefinitions(r io.Reader, defaults []LanguageDefinition) ([]LanguageDefinition, error) {
+	var config languageConfig
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	definitions := append([]LanguageDefinition(nil), defaults...)
+	for _, definition := range config.Languages {
+		replaced := false
+		for i := range definitions {
+			if strings.EqualFold(definitions[i].Name, definition.Name) {
+				definitions[i], replaced = definition, true
+				break
+			}
+		}
+		if !replaced {
+			definitions = append(definitions, definition)
+		}
+	}
+	return definitions, nil
+}
+
+// languageRegistry registry used by ParseLanguage
+var languageRegistry = mustLanguageRegistry(DefaultLanguageDefinitions)
+
+func mustLanguageRegistry(definitions []LanguageDefinition) *LanguageRegistry {
+	r, err := NewLanguageRegistry(definitions)
+	if err != nil {
+		panic(err)
 	}
-	return strings.Join(s, ", ")
+	return r
+}
+
+// SetLanguageRegistry replaces the registry used by ParseLanguage, it is not safe to call
+// while languages are parsed
+func SetLanguageRegistry(r *LanguageRegistry) {
+	languageRegistry = r
+}
+
+// RegisteredLanguages returns the languages known to ParseLanguage
+func RegisteredLanguages() []Language {
+	return languageRegistry.Languages()
 }
 
 func ParseLanguage(language string) (Language, error) {
-	switch strings.ToLower(language) {
-	case "python", "python3", "py", "py3":
-		return newLanguage(githubLanguagePython, []string{".py", ".py3"}), nil
-	case "go", "golang":
-		return newLanguage(githubLanguageGolang, []string{".go"}), nil
-	case "c#", "csharp":
-		return newLanguage(githubLanguageCSharp, []string{".cs"}), nil
-	case "c++", "cpp":
-		return newLanguage(githubLanguageCpp, []string{".cpp", ".hpp", ".cxx", ".hxx", ".cc", ".hh", ".C", ".H", ".c", ".h"}), nil
-	case "c":
-		return newLanguage(githubLanguageC, []string{".c", ".h"}), nil
-	case "java":
-		return newLanguage(githubLanguageJava, []string{".java"}), nil
-	case "javascript", "js":
-		return newLanguage(githubLanguageJavascript, []string{".js"}), nil
-	case "kotlin", "kt":
-		return newLanguage(githubLanguageKotlin, []string{".kt"}), nil
-	}
-
-	return Language{}, fmt.Errorf("unknown language: %s", language)
+	return languageRegistry.Parse(language)
+}
+
+// LanguageFileName returns a file system friendly name of a language, e.g. "csharp" for "C#"
+func LanguageFileName(language string) string {
+	return strings.NewReplacer("#", "sharp", "+", "p", " ", "_", "/", "_").Replace(strings.ToLower(language))
 }
 
 type Language struct {
-	name       githubLanguage
-	extensions map[string]bool
+	name         githubLanguage
+	extensions   map[string]bool // without the leading dot
+	compound     []string        // extensions of multiple parts like "cmake.in"
+	filenames    map[string]bool
+	interpreters map[string]bool
+	definition   LanguageDefinition
 }
 
-func newLanguage(githubLanguage githubLanguage, fileExtensions []string) Language {
-	l := Language{name: githubLanguage, extensions: make(map[string]bool)}
-	for _, extension := range fileExtensions {
-		l.extensions[strings.Replace(extension, ".", "", -1)] = true
+func newLanguage(definition LanguageDefinition) Language {
+	l := Language{
+		name:         githubLanguage(definition.Name),
+		extensions:   make(map[string]bool),
+		filenames:    make(map[string]bool),
+		interpreters: make(map[string]bool),
+		definition:   definition,
+	}
+	for _, extension := range definition.Extensions {
+		extension = strings.TrimPrefix(extension, ".")
+		if strings.Contains(extension, ".") {
+			l.compound = append(l.compound, extension)
+			continue
+		}
+		l.extensions[extension] = true
+	}
+	for _, filename := range definition.Filenames {
+		l.filenames[filename] = true
+	}
+	for _, interpreter := range definition.Interpreters {
+		l.interpreters[interpreter] = true
 	}
 	return l
 }
@@ -78,28 +208,84 @@ func (l Language) String() string {
 	return string(l.name)
 }
 
+// Definition returns the registry entry of the language
+func (l Language) Definition() LanguageDefinition {
+	return l.definition
+}
+
+// GithubQueryFilter returns all extension qualifiers and the language qualifier in one string,
+// the search would require all extensions at once, so FetchCodes searches with SearchQuery
 func (l Language) GithubQueryFilter() string {
-	return strings.Join(append(l.GithubExtensionQuery(), "language:"+l.String()), "+")
+	return strings.Join(append(l.GithubExtensionQuery(), l.GithubLanguageQuery()), "+")
 }
 
+// GithubLanguageQuery returns the search qualifier of the language, e.g. "language:Python"
+func (l Language) GithubLanguageQuery() string {
+	if len(l.definition.Query) > 0 {
+		return l.definition.Query
+	}
+	if strings.Contains(l.String(), " ") {
+		return fmt.Sprintf("language:%q", l.String())
+	}
+	return "language:" + l.String()
+}
+
+// GithubExtensionQuery returns the extension qualifiers of the language, the search only
+// supports the last part of compound extensions, so they are left out
 func (l Language) GithubExtensionQuery() []string {
 	var s []string
 	for extension := range l.extensions {
-		s = append(s, fmt.Sprintf("extension:%s", strings.Replace(extension, ".", "", -1)))
+		s = append(s, fmt.Sprintf("extension:%s", extension))
 	}
+	sort.Strings(s)
 	return s
 }
 
+// ValidFileExtension returns nil if the extension or the exact file name of filepath belongs
+// to the language, e.g. "Makefile"
 func (l Language) ValidFileExtension(filepath string) error {
-	dotPosition := strings.LastIndex(filepath, ".")
+	name := path.Base(filepath)
+	if l.filenames[name] {
+		return nil
+	}
+
+	dotPosition := strings.LastIndex(name, ".")
 	if dotPosition == -1 {
 		return fmt.Errorf("file %s has no extension", filepath)
 	}
 
-	fileExtension := filepath[dotPosition+1:]
+	fileExtension := name[dotPosition+1:]
 	if _, ok := l.extensions[fileExtension]; ok {
 		return nil
 	}
+	for _, extension := range l.compound {
+		if strings.HasSuffix(name, "."+extension) {
+			return nil
+		}
+	}
 
 	return fmt.Errorf("file %s has invalid extension for language %s", filepath, l)
 }
+
+// ValidFile returns nil if filepath is valid by ValidFileExtension, or if it has no extension
+// and the shebang line of code names an interpreter of the language, e.g. "#!/usr/bin/env python3"
+func (l Language) ValidFile(filepath string, code []byte) error {
+	err := l.ValidFileExtension(filepath)
+	if err == nil || !l.acceptsShebang(filepath) {
+		return err
+	}
+	if interpreter := shebangInterpreter(code); len(interpreter) > 0 {
+		for _, name := range interpreterNames(interpreter) {
+			if l.interpreters[name] {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("file %s has neither an extension nor a shebang of language %s", filepath, l)
+}
+
+// acceptsShebang returns true if filepath has no extension and ValidFile checks the shebang
+// line of its content
+func (l Language) acceptsShebang(filepath string) bool {
+	return len(l.interpreters) > 0 && !strings.Contains(path.Base(filepath), ".")
+}
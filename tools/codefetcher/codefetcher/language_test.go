@@ -1,6 +1,8 @@
 package codefetcher
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -35,9 +37,44 @@ func TestFileExtension(t *testing.T) {
 	}
 }
 
+func TestValidFile(t *testing.T) {
+	python, _ := ParseLanguage("python")
+	tests := []struct {
+		path  string
+		code  string
+		valid bool
+	}{
+		{"tools/SConstruct", "env = Environment()\n", true},
+		{"bin/run", "#!/usr/bin/env -S python3 -u\nprint(1)\n", true},
+		{"bin/run", "#!/usr/bin/python3.11\nprint(1)\n", true},
+		{"bin/run", "#!/bin/sh\necho 1\n", false},
+		{"bin/run", "print(1)\n", false},
+		{"src.d/run", "#!/usr/bin/env pypy3\nprint(1)\n", true},
+		{"bin/run.sh", "#!/usr/bin/env python3\nprint(1)\n", false},
+		{"Makefile", "all:\n\techo 1\n", false},
+	}
+	for _, test := range tests {
+		if err := python.ValidFile(test.path, []byte(test.code)); (err == nil) != test.valid {
+			t.Errorf("Expected valid %v for %s with %q, got %v", test.valid, test.path, test.code, err)
+		}
+	}
+
+	makefile, _ := ParseLanguage("make")
+	if err := makefile.ValidFileExtension("src/GNUmakefile"); err != nil {
+		t.Errorf("Expected GNUmakefile to be valid: %v", err)
+	}
+	if err := makefile.ValidFileExtension("src/Makefile.txt"); err == nil {
+		t.Errorf("Expected Makefile.txt to be invalid")
+	}
+	shell, _ := ParseLanguage("sh")
+	if err := shell.ValidFileExtension("home/.bashrc"); err != nil {
+		t.Errorf("Expected .bashrc to be valid: %v", err)
+	}
+}
+
 func TestGithubExtensionQuery(t *testing.T) {
-	for _, l := range AvailableLanguages {
-		language, err := ParseLanguage(string(l))
+	for _, l := range RegisteredLanguages() {
+		language, err := ParseLanguage(l.String())
 		if err != nil {
 			t.Fatalf("Expected no error when parsing language %s", l)
 		}
@@ -45,3 +82,69 @@ func TestGithubExtensionQuery(t *testing.T) {
 		t.Logf("%s: \"%v\"", language, language.GithubQueryFilter())
 	}
 }
+
+func TestLanguageRegistry(t *testing.T) {
+	for _, name := range []string{"CPP", "c++", "golang", "Py3"} {
+		if _, err := ParseLanguage(name); err != nil {
+			t.Errorf("Expected %s to be a registered language", name)
+		}
+	}
+	if language, _ := ParseLanguage("js"); language.GithubQueryFilter() != "extension:js+language:JavaScript" {
+		t.Errorf("Unexpected query filter %s", language.GithubQueryFilter())
+	}
+
+	invalid := [][]LanguageDefinition{
+		{{Name: "Rust"}},
+		{{Extensions: []string{".rs"}}},
+		{{Name: "Rust", Extensions: []string{".rs"}}, {Name: "Ruby", Aliases: []string{"rust"}, Extensions: []string{".rb"}}},
+	}
+	for i, definitions := range invalid {
+		if _, err := NewLanguageRegistry(definitions); !errors.Is(err, ErrorInvalidLanguageDefinition) {
+			t.Errorf("Expected error %s for definitions %d, got %v", ErrorInvalidLanguageDefinition, i, err)
+		}
+	}
+}
+
+func TestReadLanguageDefinitions(t *testing.T) {
+	configs := map[string]string{
+		"yaml": `languages:
+  - name: Mojo
+    aliases: [mj]
+    extensions: [.mojo]
+    query: language:mojo
+  - name: Python
+    extensions: [.py]
+    filenames: [SConstruct]
+`,
+		"json": `{"languages": [{"name": "Mojo", "aliases": ["mj"], "extensions": [".mojo"], "query": "language:mojo"},
+{"name": "Python", "extensions": [".py"], "filenames": ["SConstruct"]}]}`,
+	}
+	for format, config := range configs {
+		definitions, err := ReadLanguageDefinitions(strings.NewReader(config), DefaultLanguageDefinitions)
+		if err != nil {
+			t.Fatalf("Error reading %s definitions: %v", format, err)
+		}
+		if len(definitions) != len(DefaultLanguageDefinitions)+1 {
+			t.Fatalf("Expected Mojo to be added to the %d default languages, got %d", len(DefaultLanguageDefinitions), len(definitions))
+		}
+
+		registry, err := NewLanguageRegistry(definitions)
+		if err != nil {
+			t.Fatalf("Error creating registry from %s definitions: %v", format, err)
+		}
+		mojo, err := registry.Parse("MJ")
+		if err != nil || mojo.GithubQueryFilter() != "extension:mojo+language:mojo" || mojo.ValidFileExtension("src/main.mojo") != nil {
+			t.Fatalf("Unexpected Mojo language %+v: %v", mojo.Definition(), err)
+		}
+		if _, err = registry.Parse("py3"); err == nil {
+			t.Fatalf("Expected the Python aliases to be replaced")
+		}
+		if python, _ := registry.Parse("python"); python.Definition().Filenames[0] != "SConstruct" {
+			t.Fatalf("Unexpected Python definition %+v", python.Definition())
+		}
+	}
+
+	if _, err := ReadLanguageDefinitions(strings.NewReader("languages:\n  - name: Mojo\n    extension: [.mojo]\n"), nil); err == nil {
+		t.Fatalf("Expected unknown fields to be rejected")
+	}
+}
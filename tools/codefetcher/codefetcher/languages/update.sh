@@ -0,0 +1,18 @@
+#!/bin/bash
+
+# vendors languages.yml of GitHub Linguist unmodified, pinned to the given commit
+# $ ./update.sh <linguist commit>
+
+COMMIT=$1
+if [ -z "$COMMIT" ]; then
+  echo "usage: $0 <linguist commit>"
+  exit 1
+fi
+
+cd "$(dirname "$0")"
+curl -fsSL -o languages.yml "https://raw.githubusercontent.com/github-linguist/linguist/${COMMIT}/lib/linguist/languages.yml" || exit 1
+echo "${COMMIT}" > linguist.commit
+
+# the catalogue is embedded, a name or alias used twice would fail at startup
+go test -run 'Linguist|LanguageDefinitions|VerifyLanguage|SubQueries' .. || exit 1
+echo "Vendored languages.yml of linguist ${COMMIT}"
@@ -0,0 +1,102 @@
+package codefetcher
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// fetch skip reasons, counted in FetchSummary.Skipped
+const (
+	SkipReasonRepositoryLimit = "repository file limit"
+	SkipReasonOwnerLimit      = "owner byte limit"
+)
+
+// FetchLimits caps how much code a single repository or owner contributes, so
+// generated SDKs and mono-repos don't dominate the results of a query
+type FetchLimits struct {
+	MaxFilesPerRepository int // 0 for no limit
+	MaxBytesPerOwner      int // 0 for no limit
+}
+
+// fetchLimiter tracks the files per repository and bytes per owner during a fetch, the
+// counts are read from the storage the first time a repository or owner is seen
+type fetchLimiter struct {
+	limits  FetchLimits
+	storage Backend
+	mu      sync.Mutex
+	files   map[string]int
+	bytes   map[string]int
+}
+
+func newFetchLimiter(limits FetchLimits, storage Backend) *fetchLimiter {
+	return &fetchLimiter{
+		limits:  limits,
+		storage: storage,
+		files:   make(map[string]int),
+		bytes:   make(map[string]int),
+	}
+}
+
+// reserve checks the limits before a file of repository is downloaded, the file counts towards
+// the repository limit from now on unless it's released. Returns the skip reason if a limit is
+// reached, empty otherwise.
+func (l *fetchLimiter) reserve(ctx context.Context, repository Repository) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limits.MaxBytesPerOwner > 0 {
+		owner := strings.ToLower(repository.Owner)
+		size, ok := l.bytes[owner]
+		if !ok {
+			var err error
+			if size, err = l.storage.GetTotalCodeSizeByOwner(ctx, repository.Owner); err != nil {
+				return "", err
+			}
+			l.bytes[owner] = size
+		}
+		if size >= l.limits.MaxBytesPerOwner {
+			return SkipReasonOwnerLimit, nil
+		}
+	}
+
+	if l.limits.MaxFilesPerRepository > 0 {
+		key := strings.ToLower(repository.String())
+		files, ok := l.files[key]
+		if !ok {
+			var err error
+			if files, err = l.storage.CountCodefilesByRepository(ctx, repository); err != nil {
+				return "", err
+			}
+		}
+		if files >= l.limits.MaxFilesPerRepository {
+			l.files[key] = files
+			return SkipReasonRepositoryLimit, nil
+		}
+		l.files[key] = files + 1
+	}
+	return "", nil
+}
+
+// release returns the slot of a file reserved for repository that wasn't stored, e.g. because
+// the download failed or a filter rejected it
+func (l *fetchLimiter) release(repository Repository) {
+	if l.limits.MaxFilesPerRepository <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if key := strings.ToLower(repository.String()); l.files[key] > 0 {
+		l.files[key]--
+	}
+}
+
+// stored adds the size of a stored file to the total of its owner
+func (l *fetchLimiter) stored(repository Repository, size int) {
+	if l.limits.MaxBytesPerOwner <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.bytes[strings.ToLower(repository.Owner)] += size
+}
@@ -0,0 +1,102 @@
+package codefetcher
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestCountCodefilesByRepository(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	urls := []string{
+		"https://github.com/alice/my_repo/blob/0123/a.py",
+		"https://github.com/alice/my_repo/blob/0123/b.py",
+		"https://github.com/alice/myXrepo/blob/0123/c.py", // matched if "_" is not escaped
+		"https://github.com/alice/my_repo2/blob/0123/d.py",
+	}
+	for i, url := range urls {
+		if err := s.StoreCodefile(ctx, testLanguage1, url, []byte(fmt.Sprintf("print(%d)\n", i)), fmt.Sprintf("r%d", i)); err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+
+	count, err := s.CountCodefilesByRepository(ctx, Repository{Owner: "Alice", Name: "my_repo"})
+	if err != nil {
+		t.Fatalf("Error counting codefiles: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Expected 2 codefiles, got %d", count)
+	}
+
+	size, err := s.GetTotalCodeSizeByOwner(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Error getting total code size: %v", err)
+	}
+	if size != 4*len("print(0)\n") {
+		t.Fatalf("Expected %d bytes, got %d", 4*len("print(0)\n"), size)
+	}
+}
+
+func TestFetchLimiter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	err := s.StoreCodefile(ctx, testLanguage1, "https://github.com/alice/tools/blob/0123/a.py", []byte("print(1)\n"), "l1")
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	limiter := newFetchLimiter(FetchLimits{MaxFilesPerRepository: 2, MaxBytesPerOwner: 20}, s)
+	tools := Repository{Owner: "alice", Name: "tools"}
+	web := Repository{Owner: "alice", Name: "web"}
+
+	expected := []struct {
+		repository Repository
+		reason     string
+	}{
+		{tools, ""}, // 1 stored + 1 reserved
+		{tools, SkipReasonRepositoryLimit},
+		{web, ""},
+		{web, ""},
+		{web, SkipReasonOwnerLimit},
+	}
+	for i, e := range expected {
+		reason, err := limiter.reserve(ctx, e.repository)
+		if err != nil {
+			t.Fatalf("Error checking limits: %v", err)
+		}
+		if reason != e.reason {
+			t.Fatalf("Expected reason %q for %s at %d, got %q", e.reason, e.repository, i, reason)
+		}
+		if len(reason) == 0 {
+			limiter.stored(e.repository, 5)
+		}
+	}
+
+	// a released slot is available again, e.g. after a filter rejected the file
+	limiter = newFetchLimiter(FetchLimits{MaxFilesPerRepository: 2}, s)
+	if reason, err := limiter.reserve(ctx, tools); err != nil || len(reason) > 0 {
+		t.Fatalf("Expected no limit for %s, got %q, %v", tools, reason, err)
+	}
+	limiter.release(tools)
+	if reason, err := limiter.reserve(ctx, tools); err != nil || len(reason) > 0 {
+		t.Fatalf("Expected a released slot of %s, got %q, %v", tools, reason, err)
+	}
+	if reason, err := limiter.reserve(ctx, tools); err != nil || reason != SkipReasonRepositoryLimit {
+		t.Fatalf("Expected reason %q for %s, got %q, %v", SkipReasonRepositoryLimit, tools, reason, err)
+	}
+
+	// no limits, no storage access
+	limiter = newFetchLimiter(FetchLimits{}, Storage{})
+	if reason, err := limiter.reserve(ctx, tools); err != nil || len(reason) > 0 {
+		t.Fatalf("Expected no limit, got %q, %v", reason, err)
+	}
+}
@@ -0,0 +1,79 @@
+package codefetcher
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+var (
+	// linguistLanguages vendored languages.yml of GitHub Linguist, see ReadLinguistLanguages
+	//go:embed languages/languages.yml
+	linguistLanguages []byte
+
+	// languageOverrides corrections of linguistLanguages, see ReadLanguageDefinitions
+	//go:embed languages/overrides.yml
+	languageOverrides []byte
+)
+
+// linguistTypes types of Linguist languages read by ReadLinguistLanguages, prose like
+// Markdown and plain text is not collected
+var linguistTypes = map[string]bool{"programming": true, "markup": true, "data": true}
+
+// linguistLanguage entry of Linguist's languages.yml, other fields are ignored
+type linguistLanguage struct {
+	Type         string   `yaml:"type"`
+	Aliases      []string `yaml:"aliases"`
+	Extensions   []string `yaml:"extensions"`
+	Filenames    []string `yaml:"filenames"`
+	Interpreters []string `yaml:"interpreters"`
+}
+
+// ReadLinguistLanguages reads the languages of GitHub Linguist's languages.yml in the order of
+// the file. Prose languages and languages without extensions and file names, which can't be
+// matched, are skipped, see linguistTypes.
+func ReadLinguistLanguages(r io.Reader) ([]LanguageDefinition, error) {
+	var root yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: expected a mapping of language names", ErrorInvalidLanguageDefinition)
+	}
+
+	var definitions []LanguageDefinition
+	mapping := root.Content[0].Content
+	for i := 0; i+1 < len(mapping); i += 2 {
+		name := mapping[i].Value
+		var language linguistLanguage
+		if err := mapping[i+1].Decode(&language); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if !linguistTypes[language.Type] || len(language.Extensions) == 0 && len(language.Filenames) == 0 {
+			continue
+		}
+		definitions = append(definitions, LanguageDefinition{
+			Name:         name,
+			Aliases:      language.Aliases,
+			Extensions:   language.Extensions,
+			Filenames:    language.Filenames,
+			Interpreters: language.Interpreters,
+		})
+	}
+	return definitions, nil
+}
+
+// defaultLanguageDefinitions returns the vendored Linguist languages with the local overrides
+func defaultLanguageDefinitions() []LanguageDefinition {
+	definitions, err := ReadLinguistLanguages(bytes.NewReader(linguistLanguages))
+	if err != nil {
+		panic(fmt.Sprintf("invalid vendored languages.yml: %s", err.Error()))
+	}
+	definitions, err = ReadLanguageDefinitions(bytes.NewReader(languageOverrides), definitions)
+	if err != nil {
+		panic(fmt.Sprintf("invalid vendored overrides.yml: %s", err.Error()))
+	}
+	return definitions
+}
@@ -0,0 +1,80 @@
+package codefetcher
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLinguistLanguages(t *testing.T) {
+	const catalogue = `---
+Zig:
+  type: programming
+  color: "#ec915c"
+  extensions:
+  - ".zig"
+  language_id: 646424281
+Text:
+  type: prose
+  wrap: true
+Markdown:
+  type: prose
+  extensions:
+  - ".md"
+Makefile:
+  type: programming
+  aliases:
+  - make
+  filenames:
+  - Makefile
+`
+	definitions, err := ReadLinguistLanguages(strings.NewReader(catalogue))
+	if err != nil {
+		t.Fatalf("Error reading languages: %v", err)
+	}
+	expected := []LanguageDefinition{
+		{Name: "Zig", Extensions: []string{".zig"}},
+		{Name: "Makefile", Aliases: []string{"make"}, Filenames: []string{"Makefile"}},
+	}
+	if !reflect.DeepEqual(definitions, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, definitions)
+	}
+
+	if _, err = ReadLinguistLanguages(strings.NewReader("- Zig\n")); err == nil {
+		t.Fatalf("Expected error for a list")
+	}
+}
+
+func TestDefaultLanguageDefinitions(t *testing.T) {
+	for _, name := range []string{"rust", "TypeScript", "objc", "vb.net", "Dockerfile"} {
+		if _, err := ParseLanguage(name); err != nil {
+			t.Errorf("Expected %s to be part of the catalogue", name)
+		}
+	}
+
+	// overrides
+	python, _ := ParseLanguage("py3")
+	if python.GithubQueryFilter() != "extension:py+extension:py3+language:Python" {
+		t.Errorf("Unexpected Python query filter %s", python.GithubQueryFilter())
+	}
+	if python.ValidFileExtension("setup.cfg.spec") == nil {
+		t.Errorf("Expected .spec not to be a Python extension")
+	}
+	cpp, _ := ParseLanguage("cpp")
+	if cpp.ValidFileExtension("util.c") != nil || cpp.ValidFileExtension("util.H") != nil {
+		t.Errorf("Expected .c and .H to be C++ extensions")
+	}
+
+	vb, _ := ParseLanguage("vbnet")
+	if vb.GithubQueryFilter() != `extension:vb+extension:vbhtml+language:"Visual Basic .NET"` {
+		t.Errorf("Unexpected Visual Basic .NET query filter %s", vb.GithubQueryFilter())
+	}
+
+	cmake, _ := ParseLanguage("cmake")
+	if cmake.ValidFileExtension("cmake/config.cmake.in") != nil || cmake.ValidFileExtension("config.in") == nil {
+		t.Errorf("Expected compound extension cmake.in to be matched as a whole")
+	}
+	if cmake.GithubQueryFilter() != "extension:cmake+language:CMake" {
+		t.Errorf("Unexpected CMake query filter %s", cmake.GithubQueryFilter())
+	}
+}
@@ -0,0 +1,179 @@
+package codefetcher
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// sqlMergeProgress keeps a completed query (-1) complete, otherwise the furthest page wins
+	sqlMergeProgress = `INSERT INTO progress (language, query, last_page) VALUES (?, ?, ?)
+ON CONFLICT (language, query) DO UPDATE SET last_page = CASE
+	WHEN progress.last_page = -1 OR excluded.last_page = -1 THEN -1
+	ELSE MAX(progress.last_page, excluded.last_page)
+END;`
+	DefaultMergeBatchSize = 1000
+)
+
+// MergeCount number of merged code files of a single language
+type MergeCount struct {
+	Inserted   int `json:"inserted"`
+	Duplicates int `json:"duplicates"`
+	Tombstoned int `json:"tombstoned"` // purged from the target, see Storage.Purge
+}
+
+// MergeReport result of merging a source database, code file counts by language
+type MergeReport struct {
+	Languages  map[string]*MergeCount `json:"languages"`
+	Progress   int                    `json:"progress"`
+	Tombstones int                    `json:"tombstones"`
+	Runs       int                    `json:"runs"` // runs copied from the source, see Storage.Merge
+}
+
+func (r MergeReport) count(language string) *MergeCount {
+	if _, ok := r.Languages[language]; !ok {
+		r.Languages[language] = &MergeCount{}
+	}
+	return r.Languages[language]
+}
+
+// Add sums up the counts of another report
+func (r MergeReport) Add(other MergeReport) MergeReport {
+	if r.Languages == nil {
+		r.Languages = make(map[string]*MergeCount)
+	}
+	for language, count := range other.Languages {
+		c := r.count(language)
+		c.Inserted += count.Inserted
+		c.Duplicates += count.Duplicates
+		c.Tombstoned += count.Tombstoned
+	}
+	r.Progress += other.Progress
+	r.Tombstones += other.Tombstones
+	r.Runs += other.Runs
+	return r
+}
+
+// Merge copies all code files, tombstones and the progress of source into s. Code files
+// are inserted in transactions of batchSize rows, files with an already stored hash
+// are counted as duplicates and purged files are skipped. Merged files keep their split and
+// generated tag, so no repository moves between train and test. The runs of source are copied
+// as well, so merged files keep the run that fetched them and s.RunID is recorded as their
+// merge run. Progress is reconciled per language and query: a completed query stays
+// complete, otherwise the highest page wins.
+func (s Storage) Merge(ctx context.Context, source Storage, batchSize int) (MergeReport, error) {
+	report := MergeReport{Languages: make(map[string]*MergeCount)}
+	if s.DB == nil || source.DB == nil {
+		return report, ErrorNoDatabase
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultMergeBatchSize
+	}
+
+	tombstones, err := source.ListTombstones(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, t := range tombstones {
+		if err = insertTombstone(ctx, s.DB, t); err != nil {
+			return report, err
+		}
+		report.Tombstones++
+	}
+
+	runs, err := s.copyRuns(ctx, source)
+	if err != nil {
+		return report, err
+	}
+	report.Runs = len(runs)
+
+	var cursor int64
+	for {
+		codefiles, next, err := source.ListCodefiles(ctx, CodefileFilter{}, cursor, batchSize)
+		if err != nil {
+			return report, err
+		}
+
+		remapRuns(codefiles, runs)
+		if err = s.mergeCodefiles(ctx, codefiles, report); err != nil {
+			return report, err
+		}
+		log.Debugf("Merged %d codefiles", len(codefiles))
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	progress, err := source.ListProgress(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, p := range progress {
+		if err = s.mergeProgress(ctx, p); err != nil {
+			return report, err
+		}
+		report.Progress++
+	}
+
+	return report, nil
+}
+
+func (s Storage) mergeProgress(ctx context.Context, p Progress) error {
+	_, err := s.DB.ExecContext(ctx, sqlMergeProgress, p.Language, p.Query, p.LastPage)
+	if err != nil {
+		log.Debugf("Failed to merge progress VALUES(%s, %s, %d): %s", p.Language, p.Query, p.LastPage, err.Error())
+	}
+	return err
+}
+
+func (s Storage) mergeCodefiles(ctx context.Context, codefiles []Codefile, report MergeReport) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	config, err := s.splitConfig(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	// counts are only applied once the transaction is committed
+	counts := make(map[string]MergeCount)
+	for _, c := range codefiles {
+		count := counts[c.Language]
+		tombstoned, err := tombstoneExists(ctx, tx, c.Hash)
+		if err != nil {
+			return err
+		}
+		if tombstoned {
+			count.Tombstoned++
+			counts[c.Language] = count
+			continue
+		}
+
+		inserted, err := s.insertCodefile(ctx, tx, config, c)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			count.Inserted++
+		} else {
+			count.Duplicates++
+		}
+		counts[c.Language] = count
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for language, count := range counts {
+		c := report.count(language)
+		c.Inserted += count.Inserted
+		c.Duplicates += count.Duplicates
+		c.Tombstoned += count.Tombstoned
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package codefetcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	target := createTempDatabase(t)
+	defer target.DB.Close()
+	source := createTempDatabase(t)
+	defer source.DB.Close()
+
+	storeTestCodefiles(t, ctx, target, testLanguage1, 3)
+	storeTestCodefiles(t, ctx, source, testLanguage1, 5)
+	storeTestCodefiles(t, ctx, source, testLanguage2, 2)
+
+	progress := []struct {
+		query          string
+		target, source int
+		expected       int
+	}{
+		{"complete-target", -1, 7, -1},
+		{"complete-source", 12, -1, -1},
+		{"max-page", 12, 7, 12},
+		{"max-page-source", 3, 7, 7},
+	}
+	for _, p := range progress {
+		if err := target.UpdateProgress(ctx, testLanguage1, p.query, p.target); err != nil {
+			t.Fatalf("Error updating progress: %v", err)
+		}
+		if err := source.UpdateProgress(ctx, testLanguage1, p.query, p.source); err != nil {
+			t.Fatalf("Error updating progress: %v", err)
+		}
+	}
+	if err := source.UpdateProgress(ctx, testLanguage2, "new", 4); err != nil {
+		t.Fatalf("Error updating progress: %v", err)
+	}
+
+	report, err := target.Merge(ctx, source, 2)
+	if err != nil {
+		t.Fatalf("Error merging: %v", err)
+	}
+
+	python := report.Languages[testLanguage1.String()]
+	if python == nil || python.Inserted != 2 || python.Duplicates != 3 {
+		t.Fatalf("Expected 2 inserted and 3 duplicate %s files, got %+v", testLanguage1, python)
+	}
+	csharp := report.Languages[testLanguage2.String()]
+	if csharp == nil || csharp.Inserted != 2 || csharp.Duplicates != 0 {
+		t.Fatalf("Expected 2 inserted %s files, got %+v", testLanguage2, csharp)
+	}
+	if report.Progress != 5 {
+		t.Fatalf("Expected 5 merged progress rows, got %d", report.Progress)
+	}
+
+	count, err := target.CountCodefiles(ctx)
+	if err != nil {
+		t.Fatalf("Error counting codefiles: %v", err)
+	}
+	if count != 7 {
+		t.Fatalf("Expected 7 codefiles, got %d", count)
+	}
+
+	for _, p := range progress {
+		lastPage, err := target.GetProgress(ctx, testLanguage1, p.query)
+		if err != nil {
+			t.Fatalf("Error getting progress: %v", err)
+		}
+		if lastPage != p.expected {
+			t.Errorf("Expected progress %d for query %s, got %d", p.expected, p.query, lastPage)
+		}
+	}
+	lastPage, err := target.GetProgress(ctx, testLanguage2, "new")
+	if err != nil {
+		t.Fatalf("Error getting progress: %v", err)
+	}
+	if lastPage != 4 {
+		t.Fatalf("Expected progress 4, got %d", lastPage)
+	}
+}
+
+func TestMergeKeepsSplitAndGenerated(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	target := createTempDatabase(t)
+	defer target.DB.Close()
+	source := createTempDatabase(t)
+	defer source.DB.Close()
+
+	if err := target.SetSplitConfig(ctx, SplitConfig{Train: 1}); err != nil {
+		t.Fatalf("Error setting split config: %v", err)
+	}
+	source.SplitConfig = &SplitConfig{Test: 1}
+	err := source.StoreCodefile(ctx, testLanguage1, "https://github.com/alice/tools/blob/main/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+	// tagged by TagGenerated with a newer detector, DetectGenerated doesn't flag the content
+	if _, err = source.DB.ExecContext(ctx, `UPDATE code SET generated = 1;`); err != nil {
+		t.Fatalf("Error tagging codefile: %v", err)
+	}
+
+	if _, err = target.Merge(ctx, source, 0); err != nil {
+		t.Fatalf("Error merging: %v", err)
+	}
+	c, err := target.GetCodefileByHash(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error getting codefile: %v", err)
+	}
+	if c.Split != SplitTest || !c.Generated {
+		t.Fatalf("Expected the test split and generated tag of the source, got split=%s generated=%t", c.Split, c.Generated)
+	}
+}
+
+func TestMergeKeepsRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	target := createTempDatabase(t)
+	defer target.DB.Close()
+	source := createTempDatabase(t)
+	defer source.DB.Close()
+
+	// runs of both databases start with id 1, the fetch run must not be confused with the merge run
+	fetch, err := source.StartRun(ctx, Run{Version: "test", Arguments: []string{"fetch"}, Source: SourceGithub})
+	if err != nil {
+		t.Fatalf("Error starting run: %v", err)
+	}
+	source.RunID = fetch.ID
+	if err = source.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash); err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+	merge, err := target.StartRun(ctx, Run{Version: "test", Arguments: []string{"merge"}, Source: "merge"})
+	if err != nil {
+		t.Fatalf("Error starting run: %v", err)
+	}
+	target.RunID = merge.ID
+
+	for i := 0; i < 2; i++ {
+		report, err := target.Merge(ctx, source, 0)
+		if err != nil {
+			t.Fatalf("Error merging: %v", err)
+		}
+		if report.Runs != 1 {
+			t.Fatalf("Expected 1 copied run, got %d", report.Runs)
+		}
+	}
+
+	runs, err := target.ListRuns(ctx)
+	if err != nil {
+		t.Fatalf("Error listing runs: %v", err)
+	}
+	// merging twice reuses the copied run
+	if len(runs) != 2 || runs[1].Arguments[0] != "fetch" {
+		t.Fatalf("Expected the merge run and the copied fetch run, got %+v", runs)
+	}
+
+	c, err := target.GetCodefileByHash(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error getting codefile: %v", err)
+	}
+	if c.RunID != runs[1].ID {
+		t.Fatalf("Expected run %d of the copied fetch run, got %d", runs[1].ID, c.RunID)
+	}
+	var mergeRunID int64
+	if err = target.DB.QueryRowContext(ctx, `SELECT merge_run_id FROM code WHERE hash = ?;`, c.Hash).Scan(&mergeRunID); err != nil {
+		t.Fatalf("Error getting merge run: %v", err)
+	}
+	if mergeRunID != merge.ID {
+		t.Fatalf("Expected merge run %d, got %d", merge.ID, mergeRunID)
+	}
+}
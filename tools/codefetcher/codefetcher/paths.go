@@ -0,0 +1,81 @@
+package codefetcher
+
+import (
+	"errors"
+	"fmt"
+	"path"
+)
+
+// SkipReasonExcludedPath skip reason of files excluded by PathRules
+const SkipReasonExcludedPath = "excluded path"
+
+var (
+	ErrorInvalidPathGlob = errors.New("invalid path glob")
+)
+
+// DefaultExcludedPaths directories of vendored and third-party code, excluded for every language
+var DefaultExcludedPaths = []string{
+	"**/vendor/**",
+	"**/node_modules/**",
+	"**/third_party/**",
+	"**/third-party/**",
+	"**/thirdparty/**",
+	"**/3rdparty/**",
+	"**/external/**",
+	"**/site-packages/**",
+}
+
+// DefaultLanguageExcludedPaths package manager and dependency directories of a language,
+// excluded in addition to DefaultExcludedPaths, by Language.String()
+var DefaultLanguageExcludedPaths = map[string][]string{
+	string(githubLanguagePython): {"**/dist-packages/**", "**/venv/**", "**/.venv/**", "**/.tox/**"},
+	githubLanguageGolang:         {"**/Godeps/_workspace/**"},
+	githubLanguageCSharp:         {"**/packages/**"},
+	githubLanguageCpp:            {"**/extern/**", "**/deps/**"},
+	githubLanguageC:              {"**/extern/**", "**/deps/**"},
+	githubLanguageJavascript:     {"**/bower_components/**", "**/jspm_packages/**"},
+}
+
+// PathRules decide which files of a repository are stored by their path, globs are matched with
+// MatchPathGlob. The zero value excludes the default paths of each language.
+type PathRules struct {
+	Include    []string // only paths matching one of these globs, all if empty
+	Exclude    []string // paths excluded in addition to the defaults
+	NoDefaults bool     // don't exclude DefaultExcludedPaths and DefaultLanguageExcludedPaths
+}
+
+// Validate checks the syntax of all globs
+func (r PathRules) Validate() error {
+	for _, patterns := range [][]string{r.Include, r.Exclude} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil || len(pattern) == 0 {
+				return fmt.Errorf("%w: %q", ErrorInvalidPathGlob, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Allowed returns false if filePath of a file in language is excluded
+func (r PathRules) Allowed(language Language, filePath string) bool {
+	if len(r.Include) > 0 && !matchAnyPathGlob(r.Include, filePath) {
+		return false
+	}
+	if matchAnyPathGlob(r.Exclude, filePath) {
+		return false
+	}
+	if r.NoDefaults {
+		return true
+	}
+	return !matchAnyPathGlob(DefaultExcludedPaths, filePath) &&
+		!matchAnyPathGlob(DefaultLanguageExcludedPaths[language.String()], filePath)
+}
+
+func matchAnyPathGlob(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		if MatchPathGlob(pattern, filePath) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPathRulesAllowed(t *testing.T) {
+	python, _ := ParseLanguage("python")
+	golang, _ := ParseLanguage("go")
+
+	tests := []struct {
+		rules    PathRules
+		language Language
+		path     string
+		expected bool
+	}{
+		{PathRules{}, python, "src/main.py", true},
+		{PathRules{}, golang, "vendor/github.com/pkg/errors/errors.go", false},
+		{PathRules{}, python, "lib/python3.11/site-packages/six.py", false},
+		{PathRules{}, python, "venv/bin/activate_this.py", false},
+		{PathRules{}, golang, "venv/main.go", true}, // only a default for python
+		{PathRules{NoDefaults: true}, golang, "vendor/github.com/pkg/errors/errors.go", true},
+		{PathRules{Exclude: []string{"**/test/**"}}, python, "src/test/main.py", false},
+		{PathRules{Include: []string{"src/**"}}, python, "src/main.py", true},
+		{PathRules{Include: []string{"src/**"}}, python, "docs/conf.py", false},
+		{PathRules{Include: []string{"src/**"}}, python, "src/vendor/six.py", false},
+	}
+	for i, test := range tests {
+		if allowed := test.rules.Allowed(test.language, test.path); allowed != test.expected {
+			t.Errorf("Expected %v for test %d (%s), got %v", test.expected, i, test.path, allowed)
+		}
+	}
+}
+
+func TestPathRulesValidate(t *testing.T) {
+	if err := (PathRules{Include: []string{"src/**"}, Exclude: []string{"*.min.js"}}).Validate(); err != nil {
+		t.Fatalf("Expected valid rules, got %v", err)
+	}
+	if err := (PathRules{Exclude: []string{"["}}).Validate(); !errors.Is(err, ErrorInvalidPathGlob) {
+		t.Fatalf("Expected error %s, got %v", ErrorInvalidPathGlob, err)
+	}
+}
+
+func TestImportPathRules(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(testImportJSONL)), ImportOptions{
+		Source: "test-dataset",
+		Fields: ImportFieldMapping{Content: "text", Language: "language", Repo: "repository"},
+		Paths:  PathRules{Exclude: []string{"src/copy_*"}},
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	count := report.Languages[testLanguage1.String()]
+	if report.Skipped[SkipReasonExcludedPath] != 1 || count.Inserted != 1 || count.Duplicates != 0 {
+		t.Fatalf("Expected 1 excluded and 1 inserted file, got %+v, %+v", report.Skipped, count)
+	}
+}
@@ -0,0 +1,273 @@
+package codefetcher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxSearchQueryLength longest search string accepted by the GitHub search
+const MaxSearchQueryLength = 256
+
+// qualifiers of SearchQuery
+const (
+	qualifierLanguage  = "language"
+	qualifierPath      = "path"
+	qualifierFilename  = "filename"
+	qualifierExtension = "extension"
+	qualifierRepo      = "repo"
+	qualifierOrg       = "org"
+	qualifierSize      = "size"
+)
+
+// unsupportedQualifiers qualifiers of the GitHub search that SearchQuery doesn't model, they
+// are rejected instead of being searched as terms
+var unsupportedQualifiers = map[string]bool{"user": true, "in": true, "fork": true, "is": true, "stars": true, "pushed": true, "created": true}
+
+var (
+	searchRepo = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+	searchOrg  = regexp.MustCompile(`^[\w.-]+$`)
+	searchSize = regexp.MustCompile(`^([<>]=?)?\d+$|^\d+\.\.\d+$|^\d+\.\.\*$|^\*\.\.\d+$`)
+)
+
+// SearchQuery typed GitHub code search query. A file has a single extension and name, so
+// extensions and file names are OR-ed by splitting the query with SubQueries, while all other
+// qualifiers restrict every sub query.
+type SearchQuery struct {
+	Terms      []string // search terms, terms with spaces are searched as phrase
+	Language   Language // restricts the search to a language, see Language.GithubLanguageQuery
+	Paths      []string // directories, e.g. "src/lib"
+	Filenames  []string // exact file names, e.g. "Makefile"
+	Extensions []string // file extensions without dot
+	Repos      []string // "owner/name"
+	Orgs       []string
+	Size       string // file size in bytes, e.g. ">1000" or "100..5000"
+
+	Scripts            bool     // also search files without extension by language, see SubQueries
+	ExcludedExtensions []string // extensions excluded by "NOT extension:", set by SubQueries
+}
+
+// ParseSearchQuery parses a search string like `"http client" path:src size:<10000`, words
+// without qualifier are search terms. The language qualifier is parsed by ParseLanguage.
+func ParseSearchQuery(query string) (SearchQuery, error) {
+	var q SearchQuery
+	for _, token := range splitSearchQuery(query) {
+		qualifier, value, ok := strings.Cut(token, ":")
+		if !ok || len(qualifier) == 0 || strings.HasPrefix(token, `"`) {
+			q.Terms = append(q.Terms, strings.Trim(token, `"`))
+			continue
+		}
+		value = strings.Trim(value, `"`)
+
+		switch strings.ToLower(qualifier) {
+		case qualifierLanguage:
+			language, err := ParseLanguage(value)
+			if err != nil {
+				return q, fmt.Errorf("%w: %s", ErrorInvalidQuery, err.Error())
+			}
+			q.Language = language
+		case qualifierPath:
+			q.Paths = append(q.Paths, value)
+		case qualifierFilename:
+			q.Filenames = append(q.Filenames, value)
+		case qualifierExtension:
+			q.Extensions = append(q.Extensions, strings.TrimPrefix(value, "."))
+		case qualifierRepo:
+			q.Repos = append(q.Repos, value)
+		case qualifierOrg:
+			q.Orgs = append(q.Orgs, value)
+		case qualifierSize:
+			q.Size = value
+		default:
+			if unsupportedQualifiers[strings.ToLower(qualifier)] {
+				return q, fmt.Errorf("%w: unsupported qualifier %s", ErrorInvalidQuery, qualifier)
+			}
+			q.Terms = append(q.Terms, token) // e.g. "std::vector"
+		}
+	}
+	return q, nil
+}
+
+// splitSearchQuery splits a search string by spaces and "+", quoted phrases are kept together
+func splitSearchQuery(query string) []string {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '+'):
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// ParseLanguageSearchQuery parses query with ParseSearchQuery, restricts it to language with
+// ForLanguage and validates it. A language qualifier in query must name the same language.
+func ParseLanguageSearchQuery(language Language, query string) (SearchQuery, error) {
+	q, err := ParseSearchQuery(query)
+	if err != nil {
+		return q, err
+	}
+	if len(q.Language.String()) > 0 && q.Language.String() != language.String() {
+		return q, fmt.Errorf("%w: language %s of the query differs from %s", ErrorInvalidQuery, q.Language, language)
+	}
+	q = q.ForLanguage(language)
+	return q, q.Validate()
+}
+
+// ForLanguage returns the query restricted to language. Without extensions and file names in
+// the query, the extensions and file names of the language are searched, as well as scripts
+// without extension if the language has interpreters.
+func (q SearchQuery) ForLanguage(language Language) SearchQuery {
+	q.Language = language
+	if len(q.Extensions) == 0 && len(q.Filenames) == 0 {
+		q.Extensions = make([]string, 0, len(language.extensions))
+		for extension := range language.extensions {
+			q.Extensions = append(q.Extensions, extension)
+		}
+		sort.Strings(q.Extensions)
+		q.Filenames = append([]string(nil), language.definition.Filenames...)
+		q.Scripts = len(language.interpreters) > 0
+	}
+	return q
+}
+
+// Validate rejects queries the search would refuse or that can't find files of the language
+func (q SearchQuery) Validate() error {
+	if len(q.Terms) == 0 {
+		return fmt.Errorf("%w: at least one search term is required", ErrorInvalidQuery)
+	}
+	for _, term := range q.Terms {
+		if len(strings.TrimSpace(term)) == 0 || strings.Contains(term, `"`) {
+			return fmt.Errorf("%w: invalid term %q", ErrorInvalidQuery, term)
+		}
+	}
+	if len(q.Repos) > 0 && len(q.Orgs) > 0 {
+		return fmt.Errorf("%w: repo and org are mutually exclusive", ErrorInvalidQuery)
+	}
+	for _, repo := range q.Repos {
+		if !searchRepo.MatchString(repo) {
+			return fmt.Errorf("%w: repo %q is not owner/name", ErrorInvalidQuery, repo)
+		}
+	}
+	for _, org := range q.Orgs {
+		if !searchOrg.MatchString(org) {
+			return fmt.Errorf("%w: invalid org %q", ErrorInvalidQuery, org)
+		}
+	}
+	if len(q.Size) > 0 && !searchSize.MatchString(q.Size) {
+		return fmt.Errorf("%w: invalid size %q", ErrorInvalidQuery, q.Size)
+	}
+	for _, path := range q.Paths {
+		if len(strings.Trim(path, "/")) == 0 || strings.Contains(path, `"`) {
+			return fmt.Errorf("%w: invalid path %q", ErrorInvalidQuery, path)
+		}
+	}
+
+	// results of other extensions and file names would be skipped by ValidFile
+	for _, extension := range q.Extensions {
+		if len(extension) == 0 || strings.ContainsAny(extension, `./ "`) {
+			return fmt.Errorf("%w: invalid extension %q", ErrorInvalidQuery, extension)
+		}
+		if len(q.Language.String()) > 0 && !q.Language.extensions[extension] {
+			return fmt.Errorf("%w: extension %s is not an extension of %s", ErrorInvalidQuery, extension, q.Language)
+		}
+	}
+	for _, filename := range q.Filenames {
+		if len(filename) == 0 || strings.ContainsAny(filename, `/"`) {
+			return fmt.Errorf("%w: invalid file name %q", ErrorInvalidQuery, filename)
+		}
+		if len(q.Language.String()) > 0 && q.Language.ValidFileExtension(filename) != nil && !q.Language.acceptsShebang(filename) {
+			return fmt.Errorf("%w: file name %s is not a file of %s", ErrorInvalidQuery, filename, q.Language)
+		}
+	}
+
+	for _, sub := range q.SubQueries() {
+		if query := sub.String(); len(query) > MaxSearchQueryLength {
+			return fmt.Errorf("%w: %q is longer than %d characters", ErrorInvalidQuery, query, MaxSearchQueryLength)
+		}
+	}
+	return nil
+}
+
+// SubQueries splits the query into one query per extension and file name, the results of the
+// sub queries are the results of the query. Scripts are searched by the language qualifier
+// alone, excluding the extensions searched by the other sub queries.
+func (q SearchQuery) SubQueries() []SearchQuery {
+	if len(q.Extensions)+len(q.Filenames) <= 1 && !q.Scripts {
+		return []SearchQuery{q}
+	}
+	var queries []SearchQuery
+	for _, extension := range q.Extensions {
+		sub := q
+		sub.Extensions, sub.Filenames = []string{extension}, nil
+		queries = append(queries, sub)
+	}
+	for _, filename := range q.Filenames {
+		sub := q
+		sub.Extensions, sub.Filenames = nil, []string{filename}
+		queries = append(queries, sub)
+	}
+	if q.Scripts {
+		sub := q
+		sub.Extensions, sub.Filenames, sub.Scripts = nil, nil, false
+		sub.ExcludedExtensions = append([]string(nil), q.Extensions...)
+		// the exclusions only save downloads of files found by the other sub queries, they
+		// are left out if the query gets too long
+		for len(sub.String()) > MaxSearchQueryLength && len(sub.ExcludedExtensions) > 0 {
+			sub.ExcludedExtensions = sub.ExcludedExtensions[:len(sub.ExcludedExtensions)-1]
+		}
+		queries = append(queries, sub)
+	}
+	return queries
+}
+
+// String returns the search string, terms followed by the qualifiers. Multiple extensions or
+// file names are AND-ed by the search, use SubQueries to search them.
+func (q SearchQuery) String() string {
+	var parts []string
+	for _, term := range q.Terms {
+		parts = append(parts, quoteSearchValue(term))
+	}
+	if len(q.Language.String()) > 0 {
+		parts = append(parts, q.Language.GithubLanguageQuery())
+	}
+	qualify := func(qualifier string, values []string) {
+		for _, value := range values {
+			parts = append(parts, qualifier+":"+quoteSearchValue(value))
+		}
+	}
+	qualify(qualifierPath, q.Paths)
+	qualify(qualifierFilename, q.Filenames)
+	qualify(qualifierExtension, q.Extensions)
+	for _, extension := range q.ExcludedExtensions {
+		parts = append(parts, "NOT "+qualifierExtension+":"+quoteSearchValue(extension))
+	}
+	qualify(qualifierRepo, q.Repos)
+	qualify(qualifierOrg, q.Orgs)
+	if len(q.Size) > 0 {
+		qualify(qualifierSize, []string{q.Size})
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteSearchValue quotes values with spaces
+func quoteSearchValue(value string) string {
+	if strings.ContainsAny(value, " \t+") {
+		return `"` + value + `"`
+	}
+	return value
+}
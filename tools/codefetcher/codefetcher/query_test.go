@@ -0,0 +1,128 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	q, err := ParseSearchQuery(`"http client"+std::vector path:"src/my lib" filename:Makefile extension:.py repo:alice/tools size:<10000`)
+	if err != nil {
+		t.Fatalf("Error parsing query: %v", err)
+	}
+	expected := SearchQuery{
+		Terms:      []string{"http client", "std::vector"},
+		Paths:      []string{"src/my lib"},
+		Filenames:  []string{"Makefile"},
+		Extensions: []string{"py"},
+		Repos:      []string{"alice/tools"},
+		Size:       "<10000",
+	}
+	if !reflect.DeepEqual(q, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, q)
+	}
+	if q.String() != `"http client" std::vector path:"src/my lib" filename:Makefile extension:py repo:alice/tools size:<10000` {
+		t.Fatalf("Unexpected search string %s", q.String())
+	}
+
+	for _, query := range []string{"main user:alice", "main language:cobol"} {
+		if _, err = ParseSearchQuery(query); !errors.Is(err, ErrorInvalidQuery) {
+			t.Errorf("Expected error %s for %q, got %v", ErrorInvalidQuery, query, err)
+		}
+	}
+}
+
+func TestSubQueries(t *testing.T) {
+	python, _ := ParseLanguage("python")
+	q, err := ParseLanguageSearchQuery(python, "import path:src")
+	if err != nil {
+		t.Fatalf("Error parsing query: %v", err)
+	}
+
+	var queries []string
+	for _, sub := range q.SubQueries() {
+		queries = append(queries, sub.String())
+	}
+	expected := []string{
+		"import language:Python path:src extension:py",
+		"import language:Python path:src extension:py3",
+	}
+	for _, filename := range python.Definition().Filenames {
+		expected = append(expected, "import language:Python path:src filename:"+filename)
+	}
+	// extensionless scripts like "run" with a python shebang
+	expected = append(expected, "import language:Python path:src NOT extension:py NOT extension:py3")
+	if !reflect.DeepEqual(queries, expected) {
+		t.Fatalf("Expected %v, got %v", expected, queries)
+	}
+
+	q, err = ParseLanguageSearchQuery(python, "import extension:py language:py3")
+	if err != nil {
+		t.Fatalf("Error parsing query: %v", err)
+	}
+	if subQueries := q.SubQueries(); len(subQueries) != 1 || subQueries[0].String() != "import language:Python extension:py" {
+		t.Fatalf("Expected a single query for extension py, got %+v", subQueries)
+	}
+
+	// the exclusions are dropped before the script query gets too long
+	shell, _ := ParseLanguage("shell")
+	q, err = ParseLanguageSearchQuery(shell, strings.Repeat("echo ", 40))
+	if err != nil {
+		t.Fatalf("Error parsing query: %v", err)
+	}
+	subQueries := q.SubQueries()
+	scripts := subQueries[len(subQueries)-1]
+	if len(scripts.Extensions) > 0 || len(scripts.ExcludedExtensions) >= len(q.Extensions) || len(scripts.String()) > MaxSearchQueryLength {
+		t.Fatalf("Expected a script query with fewer exclusions, got %s", scripts)
+	}
+}
+
+func TestValidateSearchQuery(t *testing.T) {
+	python, _ := ParseLanguage("python")
+	invalid := []string{
+		"",
+		"path:src",
+		"import language:go",
+		"import extension:rs",
+		"import filename:Cargo.toml",
+		"import repo:alice",
+		"import repo:alice/tools org:bob",
+		"import org:alice/tools",
+		"import size:big",
+		"import size:>",
+		"import path:/",
+		strings.Repeat("import ", MaxSearchQueryLength/len("import ")+1),
+	}
+	for _, query := range invalid {
+		if _, err := ParseLanguageSearchQuery(python, query); !errors.Is(err, ErrorInvalidQuery) {
+			t.Errorf("Expected error %s for %q, got %v", ErrorInvalidQuery, query, err)
+		}
+	}
+
+	valid := []string{
+		"import",
+		"import org:alice size:100..5000",
+		"import repo:alice/tools repo:bob/tools size:>=100",
+		"import filename:SConstruct",
+		"import filename:run",
+	}
+	for _, query := range valid {
+		if _, err := ParseLanguageSearchQuery(python, query); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", query, err)
+		}
+	}
+}
+
+func TestFetchCodesInvalidQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	fetcher := NewGithubFetcher("", "", nil, 0)
+	python, _ := ParseLanguage("python")
+	if _, err := fetcher.FetchCodes(ctx, python, "import extension:go", 0); !errors.Is(err, ErrorInvalidQuery) {
+		t.Fatalf("Expected error %s, got %v", ErrorInvalidQuery, err)
+	}
+}
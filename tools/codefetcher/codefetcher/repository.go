@@ -0,0 +1,46 @@
+package codefetcher
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Repository a GitHub repository identified by owner and name
+type Repository struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+}
+
+// githubURL prefix of all GitHub repository urls
+const githubURL = "https://github.com/"
+
+func (r Repository) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+// URL returns the GitHub url of the repository
+func (r Repository) URL() string {
+	return githubURL + r.String()
+}
+
+// ParseRepositoryURL parses the repository and file path of a code file URL as stored by
+// the fetcher, e.g. "https://github.com/owner/repo/blob/<ref>/path/to/file.py"
+func ParseRepositoryURL(rawURL string) (Repository, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Repository{}, "", err
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return Repository{}, "", fmt.Errorf("no repository in url %s", rawURL)
+	}
+
+	repository := Repository{Owner: parts[0], Name: parts[1]}
+	var path string
+	if len(parts) > 4 && (parts[2] == "blob" || parts[2] == "raw" || parts[2] == "tree") {
+		path = strings.Join(parts[4:], "/")
+	}
+	return repository, path, nil
+}
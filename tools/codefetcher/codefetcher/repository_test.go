@@ -0,0 +1,33 @@
+package codefetcher
+
+import (
+	"testing"
+)
+
+func TestParseRepositoryURL(t *testing.T) {
+	tests := []struct {
+		url        string
+		repository Repository
+		path       string
+	}{
+		{"https://github.com/kev-inn/programming-languages-w2v/blob/0123abcd/tools/codefetcher/main.go", Repository{"kev-inn", "programming-languages-w2v"}, "tools/codefetcher/main.go"},
+		{"https://github.com/owner/repo/blob/0123abcd/main.py", Repository{"owner", "repo"}, "main.py"},
+		{"https://github.com/owner/repo", Repository{"owner", "repo"}, ""},
+	}
+	for _, test := range tests {
+		repository, path, err := ParseRepositoryURL(test.url)
+		if err != nil {
+			t.Errorf("Error parsing %s: %v", test.url, err)
+			continue
+		}
+		if repository != test.repository || path != test.path {
+			t.Errorf("Expected %s and path %s for %s, got %s and path %s", test.repository, test.path, test.url, repository, path)
+		}
+	}
+
+	for _, url := range []string{"http://localhost/main.py", "https://github.com/", "://"} {
+		if _, _, err := ParseRepositoryURL(url); err == nil {
+			t.Errorf("Expected error parsing %s", url)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package codefetcher
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	sqlCreateTableRuns = `CREATE TABLE IF NOT EXISTS "runs" (
+	"id"	INTEGER,
+	"started_at"	TEXT NOT NULL,
+	"finished_at"	TEXT,
+	"version"	TEXT NOT NULL,
+	"arguments"	TEXT NOT NULL,
+	"source"	TEXT NOT NULL,
+	"languages"	TEXT NOT NULL,
+	"queries"	TEXT NOT NULL,
+	"searched"	INTEGER NOT NULL DEFAULT 0,
+	"downloaded"	INTEGER NOT NULL DEFAULT 0,
+	"stored"	INTEGER NOT NULL DEFAULT 0,
+	"duplicates"	INTEGER NOT NULL DEFAULT 0,
+	"skipped"	TEXT NOT NULL DEFAULT '{}',
+	"error"	TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY("id" AUTOINCREMENT)
+);`
+	sqlInsertRun = `INSERT INTO runs (started_at, version, arguments, source, languages, queries) VALUES (?, ?, ?, ?, ?, ?);`
+	sqlFinishRun = `UPDATE runs SET finished_at = ?, searched = ?, downloaded = ?, stored = ?, duplicates = ?, skipped = ?, mismatches = ?, error = ? WHERE id = ?;`
+	sqlSelectRun = `SELECT id, started_at, COALESCE(finished_at, ''), version, arguments, source, languages, queries,
+	searched, downloaded, stored, duplicates, skipped, COALESCE(mismatches, '{}'), error FROM runs`
+	sqlCopyRun = `INSERT INTO runs (started_at, finished_at, version, arguments, source, languages, queries,
+	searched, downloaded, stored, duplicates, skipped, mismatches, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	sqlFindRun  = `SELECT id FROM runs WHERE started_at = ? AND version = ? AND arguments = ? AND source = ?;`
+	sqlGetRun   = sqlSelectRun + ` WHERE id = ?;`
+	sqlListRuns = sqlSelectRun + ` ORDER BY id;`
+)
+
+var (
+	ErrorRunNotFound = errors.New("run not found")
+)
+
+// RunRecorder is implemented by the backends recording runs, see Storage.StartRun
+type RunRecorder interface {
+	StartRun(ctx context.Context, run Run) (Run, error)
+	FinishRun(ctx context.Context, run Run) (Run, error)
+	ListRuns(ctx context.Context) ([]Run, error)
+}
+
+// Run provenance of a single invocation that stored code files, FinishedAt is
+// zero while the run is in progress or if it was killed
+type Run struct {
+	ID         int64        `json:"id"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Version    string       `json:"version"`
+	Arguments  []string     `json:"arguments"` // command line with secrets redacted
+	Source     string       `json:"source"`
+	Languages  []string     `json:"languages"`
+	Queries    []string     `json:"queries"`
+	Summary    FetchSummary `json:"summary"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// StartRun records the start of run, the returned run holds the id used to tag stored
+// code files (Storage.RunID)
+func (s Storage) StartRun(ctx context.Context, run Run) (Run, error) {
+	if s.DB == nil {
+		return run, ErrorNoDatabase
+	}
+	run.StartedAt = time.Now().UTC().Truncate(time.Second)
+
+	values := runValues(run)
+	result, err := s.DB.ExecContext(ctx, sqlInsertRun, run.StartedAt.Format(time.RFC3339), run.Version,
+		values[0], run.Source, values[1], values[2])
+	if err != nil {
+		log.Debugf("Failed to insert run: %s", err.Error())
+		return run, err
+	}
+	run.ID, err = result.LastInsertId()
+	return run, err
+}
+
+// FinishRun records the end of run along with its summary and error, if any
+func (s Storage) FinishRun(ctx context.Context, run Run) (Run, error) {
+	if s.DB == nil {
+		return run, ErrorNoDatabase
+	}
+	run.FinishedAt = time.Now().UTC().Truncate(time.Second)
+
+	counts := summaryValues(run.Summary)
+	summary := run.Summary
+	_, err := s.DB.ExecContext(ctx, sqlFinishRun, run.FinishedAt.Format(time.RFC3339), summary.Searched, summary.Downloaded,
+		summary.Stored, summary.Duplicates, counts[0], counts[1], run.Error, run.ID)
+	if err != nil {
+		log.Debugf("Failed to finish run %d: %s", run.ID, err.Error())
+	}
+	return run, err
+}
+
+// copyRuns copies all runs of source into s, runs copied by an earlier merge are reused.
+// Returns the ids in s by the ids in source.
+func (s Storage) copyRuns(ctx context.Context, source Storage) (map[int64]int64, error) {
+	runs, err := source.ListRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int64]int64, len(runs))
+	for _, run := range runs {
+		if ids[run.ID], err = s.copyRun(ctx, run); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func (s Storage) copyRun(ctx context.Context, run Run) (int64, error) {
+	values := runValues(run)
+	startedAt := run.StartedAt.Format(time.RFC3339)
+
+	var id int64
+	err := s.DB.QueryRowContext(ctx, sqlFindRun, startedAt, run.Version, values[0], run.Source).Scan(&id)
+	if err == nil {
+		return id, nil
+	} else if err != sql.ErrNoRows {
+		log.Debugf("Failed to find run %d: %s", run.ID, err.Error())
+		return 0, err
+	}
+
+	var finishedAt any
+	if !run.FinishedAt.IsZero() {
+		finishedAt = run.FinishedAt.Format(time.RFC3339)
+	}
+	counts := summaryValues(run.Summary)
+	summary := run.Summary
+	result, err := s.DB.ExecContext(ctx, sqlCopyRun, startedAt, finishedAt, run.Version, values[0], run.Source, values[1], values[2],
+		summary.Searched, summary.Downloaded, summary.Stored, summary.Duplicates, counts[0], counts[1], run.Error)
+	if err != nil {
+		log.Debugf("Failed to copy run %d: %s", run.ID, err.Error())
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// remapRuns replaces the run ids of codefiles by the ids returned by copyRuns, unknown runs are dropped
+func remapRuns(codefiles []Codefile, runs map[int64]int64) {
+	for i := range codefiles {
+		codefiles[i].RunID = runs[codefiles[i].RunID]
+	}
+}
+
+// runValues returns the JSON columns arguments, languages and queries of run
+func runValues(run Run) [3]string {
+	var values [3]string
+	for i, v := range [][]string{run.Arguments, run.Languages, run.Queries} {
+		if v == nil {
+			v = []string{}
+		}
+		data, _ := json.Marshal(v)
+		values[i] = string(data)
+	}
+	return values
+}
+
+// summaryValues returns the JSON columns skipped and mismatches of summary
+func summaryValues(summary FetchSummary) [2]string {
+	var values [2]string
+	for i, m := range []map[string]int{summary.Skipped, summary.Mismatches} {
+		if m == nil {
+			m = map[string]int{}
+		}
+		data, _ := json.Marshal(m)
+		values[i] = string(data)
+	}
+	return values
+}
+
+func scanRun(row rowScanner) (Run, error) {
+	var run Run
+	var startedAt, finishedAt, arguments, languages, queries, skipped, mismatches string
+	err := row.Scan(&run.ID, &startedAt, &finishedAt, &run.Version, &arguments, &run.Source, &languages, &queries,
+		&run.Summary.Searched, &run.Summary.Downloaded, &run.Summary.Stored, &run.Summary.Duplicates, &skipped, &mismatches, &run.Error)
+	if err != nil {
+		return Run{}, err
+	}
+
+	if run.StartedAt, err = time.Parse(time.RFC3339, startedAt); err != nil {
+		return Run{}, err
+	}
+	if len(finishedAt) > 0 {
+		if run.FinishedAt, err = time.Parse(time.RFC3339, finishedAt); err != nil {
+			return Run{}, err
+		}
+	}
+	for _, v := range []struct {
+		data string
+		dest any
+	}{{arguments, &run.Arguments}, {languages, &run.Languages}, {queries, &run.Queries}, {skipped, &run.Summary.Skipped}, {mismatches, &run.Summary.Mismatches}} {
+		if err = json.Unmarshal([]byte(v.data), v.dest); err != nil {
+			return Run{}, err
+		}
+	}
+	return run, nil
+}
+
+func (s Storage) GetRun(ctx context.Context, id int64) (Run, error) {
+	if s.DB == nil {
+		return Run{}, ErrorNoDatabase
+	}
+	run, err := scanRun(s.DB.QueryRowContext(ctx, sqlGetRun, id))
+	if err == sql.ErrNoRows {
+		return Run{}, fmt.Errorf("%w: %d", ErrorRunNotFound, id)
+	} else if err != nil {
+		log.Debugf("Failed to get run %d: %s", id, err.Error())
+		return Run{}, err
+	}
+	return run, nil
+}
+
+func (s Storage) ListRuns(ctx context.Context) ([]Run, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListRuns)
+	if err != nil {
+		log.Debugf("Failed to list runs: %s", err.Error())
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+// scanRuns reads and closes rows of sqlSelectRun
+func scanRuns(rows *sql.Rows) ([]Run, error) {
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -0,0 +1,82 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	run, err := s.StartRun(ctx, Run{
+		Version:   "v1.0.0",
+		Arguments: []string{"--github-token=REDACTED", "fetch"},
+		Source:    SourceGithub,
+		Languages: []string{testLanguage1.String()},
+		Queries:   []string{"print"},
+	})
+	if err != nil {
+		t.Fatalf("Error starting run: %v", err)
+	}
+	if run.ID == 0 {
+		t.Fatalf("Expected run id")
+	}
+
+	s.RunID = run.ID
+	err = s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+	var runID int64
+	if err = s.DB.QueryRowContext(ctx, `SELECT run_id FROM code WHERE hash = ?;`, testCodefileHelloWorldHash).Scan(&runID); err != nil {
+		t.Fatalf("Error getting run id: %v", err)
+	}
+	if runID != run.ID {
+		t.Fatalf("Expected run id %d, got %d", run.ID, runID)
+	}
+
+	run.Summary = FetchSummary{Searched: 30, Downloaded: 2, Stored: 1, Duplicates: 4, Skipped: map[string]int{SkipReasonInvalidExtension: 3}, Mismatches: map[string]int{"C -> C++": 1}}
+	run.Error = "context canceled"
+	if run, err = s.FinishRun(ctx, run); err != nil {
+		t.Fatalf("Error finishing run: %v", err)
+	}
+
+	stored, err := s.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("Error getting run: %v", err)
+	}
+	if !reflect.DeepEqual(stored, run) {
+		t.Fatalf("Expected run %+v, got %+v", run, stored)
+	}
+
+	if _, err = s.GetRun(ctx, run.ID+1); !errors.Is(err, ErrorRunNotFound) {
+		t.Fatalf("Expected error %s, got %v", ErrorRunNotFound, err)
+	}
+
+	if _, err = s.StartRun(ctx, Run{Source: "test"}); err != nil {
+		t.Fatalf("Error starting run: %v", err)
+	}
+	runs, err := s.ListRuns(ctx)
+	if err != nil {
+		t.Fatalf("Error listing runs: %v", err)
+	}
+	if len(runs) != 2 || !runs[1].FinishedAt.IsZero() {
+		t.Fatalf("Expected a finished and a running run, got %+v", runs)
+	}
+}
+
+func TestFetchSummaryAdd(t *testing.T) {
+	a := FetchSummary{Searched: 1, Stored: 1, Skipped: map[string]int{SkipReasonCodeSizeLimit: 1}}
+	b := FetchSummary{Searched: 2, Duplicates: 1, Skipped: map[string]int{SkipReasonCodeSizeLimit: 2, SkipReasonOwnerLimit: 1}, Mismatches: map[string]int{"C++ -> C": 1}}
+	sum := a.Add(b)
+	expected := FetchSummary{Searched: 3, Stored: 1, Duplicates: 1, Skipped: map[string]int{SkipReasonCodeSizeLimit: 3, SkipReasonOwnerLimit: 1}, Mismatches: map[string]int{"C++ -> C": 1}}
+	if !reflect.DeepEqual(sum, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, sum)
+	}
+}
@@ -0,0 +1,266 @@
+package codefetcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SampleUnit measure balanced by Storage.Sample
+type SampleUnit string
+
+const (
+	SampleFiles  SampleUnit = "files"
+	SampleBytes  SampleUnit = "bytes"
+	SampleTokens SampleUnit = "tokens"
+)
+
+func ParseSampleUnit(unit string) (SampleUnit, error) {
+	switch SampleUnit(strings.ToLower(unit)) {
+	case SampleFiles:
+		return SampleFiles, nil
+	case SampleBytes:
+		return SampleBytes, nil
+	case SampleTokens:
+		return SampleTokens, nil
+	}
+	return "", fmt.Errorf("unknown sample unit %s", unit)
+}
+
+// SampleOptions parameters of Storage.Sample
+type SampleOptions struct {
+	Filter               CodefileFilter // restricts the candidates, e.g. to a split
+	Unit                 SampleUnit
+	Size                 int   // amount per language in Unit, 0 selects the amount of the smallest language
+	Seed                 int64 // the same seed and database always yield the same sample
+	StratifyByRepository bool  // spread the sample evenly over the repositories of a language
+	MaxPerRepository     int   // maximum number of files per repository, 0 for no limit
+}
+
+// SampleCount amount of sampled code of a single language
+type SampleCount struct {
+	Files  int `json:"files"`
+	Bytes  int `json:"bytes"`
+	Tokens int `json:"tokens,omitempty"`
+}
+
+// SampledFile a code file selected by Storage.Sample
+type SampledFile struct {
+	ID       int64  `json:"id"`
+	Hash     string `json:"hash"`
+	Language string `json:"language"`
+	URL      string `json:"url"`
+}
+
+// Sample a language-balanced selection of code files, Files are ordered by language and id
+type Sample struct {
+	Unit      SampleUnit              `json:"unit"`
+	Size      int                     `json:"size"`
+	Seed      int64                   `json:"seed"`
+	Languages map[string]*SampleCount `json:"languages"`
+	Files     []SampledFile           `json:"files"`
+}
+
+type sampleCandidate struct {
+	file   SampledFile
+	repo   string
+	size   int
+	tokens int
+}
+
+func (c sampleCandidate) measure(unit SampleUnit) int {
+	switch unit {
+	case SampleBytes:
+		return c.size
+	case SampleTokens:
+		return c.tokens
+	}
+	return 1
+}
+
+// Sample selects the same amount of code for every language: Size files, bytes or tokens
+// per language, picked at random with a generator seeded by Seed and the language name.
+// The last file of a language may exceed Size when sampling bytes or tokens.
+func (s Storage) Sample(ctx context.Context, options SampleOptions) (Sample, error) {
+	sample := Sample{Unit: options.Unit, Seed: options.Seed, Languages: make(map[string]*SampleCount)}
+	if _, err := ParseSampleUnit(string(options.Unit)); err != nil {
+		return sample, err
+	}
+
+	filter := options.Filter
+	filter.SkipContent = options.Unit != SampleTokens
+
+	candidates := make(map[string][]sampleCandidate)
+	err := s.IterateCodefiles(ctx, filter, func(c Codefile) error {
+		candidate := sampleCandidate{
+			file: SampledFile{ID: c.ID, Hash: c.Hash, Language: c.Language, URL: c.URL},
+			repo: c.URL,
+			size: c.Size,
+		}
+		if repository, _, err := ParseRepositoryURL(c.URL); err == nil {
+			candidate.repo = strings.ToLower(repository.String())
+		}
+		if options.Unit == SampleTokens {
+			candidate.tokens = CountTokens(c.Content)
+		}
+		candidates[c.Language] = append(candidates[c.Language], candidate)
+		return nil
+	})
+	if err != nil {
+		return sample, err
+	}
+
+	var languages []string
+	for language := range candidates {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	// the order in which files are picked, capped per repository
+	ordered := make(map[string][]sampleCandidate)
+	size := options.Size
+	for i, language := range languages {
+		h := fnv.New64a()
+		h.Write([]byte(language))
+		rng := rand.New(rand.NewSource(options.Seed ^ int64(h.Sum64())))
+
+		order := sampleOrder(candidates[language], rng, options.StratifyByRepository)
+		if options.MaxPerRepository > 0 {
+			perRepository := make(map[string]int)
+			capped := order[:0]
+			for _, c := range order {
+				if perRepository[c.repo] < options.MaxPerRepository {
+					perRepository[c.repo]++
+					capped = append(capped, c)
+				}
+			}
+			order = capped
+		}
+		ordered[language] = order
+
+		if options.Size <= 0 {
+			var total int
+			for _, c := range order {
+				total += c.measure(options.Unit)
+			}
+			if i == 0 || total < size {
+				size = total
+			}
+		}
+	}
+	sample.Size = size
+
+	for _, language := range languages {
+		count := &SampleCount{}
+		var files []SampledFile
+		var total int
+		for _, c := range ordered[language] {
+			if total >= size {
+				break
+			}
+			total += c.measure(options.Unit)
+			count.Files++
+			count.Bytes += c.size
+			count.Tokens += c.tokens
+			files = append(files, c.file)
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+		sample.Files = append(sample.Files, files...)
+		sample.Languages[language] = count
+	}
+	return sample, nil
+}
+
+// sampleOrder shuffles candidates, stratified the repositories take turns so every
+// repository contributes before any repository contributes twice
+func sampleOrder(candidates []sampleCandidate, rng *rand.Rand, stratify bool) []sampleCandidate {
+	if !stratify {
+		order := append([]sampleCandidate{}, candidates...)
+		rng.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		return order
+	}
+
+	groups := make(map[string][]sampleCandidate)
+	var repositories []string
+	for _, c := range candidates {
+		if _, ok := groups[c.repo]; !ok {
+			repositories = append(repositories, c.repo)
+		}
+		groups[c.repo] = append(groups[c.repo], c)
+	}
+	rng.Shuffle(len(repositories), func(i, j int) { repositories[i], repositories[j] = repositories[j], repositories[i] })
+	for _, repository := range repositories {
+		group := groups[repository]
+		rng.Shuffle(len(group), func(i, j int) { group[i], group[j] = group[j], group[i] })
+	}
+
+	order := make([]sampleCandidate, 0, len(candidates))
+	for round := 0; len(order) < len(candidates); round++ {
+		for _, repository := range repositories {
+			if group := groups[repository]; round < len(group) {
+				order = append(order, group[round])
+			}
+		}
+	}
+	return order
+}
+
+// ExportSample writes the code files of sample to e, grouped by language. Returns the number of exported files.
+func (s Storage) ExportSample(ctx context.Context, sample Sample, e Exporter) (int, error) {
+	for i, file := range sample.Files {
+		c, err := s.GetCodefileByID(ctx, file.ID)
+		if err != nil {
+			return i, err
+		}
+		if err = e.Write(c); err != nil {
+			return i, err
+		}
+	}
+	return len(sample.Files), nil
+}
+
+// WriteManifest writes the sample parameters, counts and the selected hashes by language as JSON
+func (sample Sample) WriteManifest(w io.Writer) error {
+	hashes := make(map[string][]string)
+	for _, file := range sample.Files {
+		hashes[file.Language] = append(hashes[file.Language], file.Hash)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Unit      SampleUnit              `json:"unit"`
+		Size      int                     `json:"size"`
+		Seed      int64                   `json:"seed"`
+		Languages map[string]*SampleCount `json:"languages"`
+		Hashes    map[string][]string     `json:"hashes"`
+	}{sample.Unit, sample.Size, sample.Seed, sample.Languages, hashes})
+}
+
+// CountTokens returns the number of tokens of content, a token being a run of letters,
+// digits and underscores or any other single non-space character
+func CountTokens(content []byte) int {
+	var tokens int
+	inWord := false
+	for _, r := range string(content) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if !inWord {
+				tokens++
+			}
+			inWord = true
+		case unicode.IsSpace(r):
+			inWord = false
+		default:
+			tokens++
+			inWord = false
+		}
+	}
+	return tokens
+}
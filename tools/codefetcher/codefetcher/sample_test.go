@@ -0,0 +1,167 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected int
+	}{
+		{"", 0},
+		{"print(1)\n", 4},
+		{"x_1 = y+2;", 6},
+		{"  \n\t", 0},
+	}
+	for _, test := range tests {
+		if tokens := CountTokens([]byte(test.content)); tokens != test.expected {
+			t.Errorf("Expected %d tokens for %q, got %d", test.expected, test.content, tokens)
+		}
+	}
+}
+
+func storeSampleTestCodefiles(t *testing.T, ctx context.Context, s Storage) {
+	// 20 Python files in 2 repositories, 4 C# files in 4 repositories
+	for i := 0; i < 20; i++ {
+		url := fmt.Sprintf("https://github.com/alice/repo%d/blob/0123/f%d.py", i%2, i)
+		if err := s.StoreCodefile(ctx, testLanguage1, url, []byte(fmt.Sprintf("print(%d)\n", i)), fmt.Sprintf("p%d", i)); err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		url := fmt.Sprintf("https://github.com/bob/repo%d/blob/0123/F%d.cs", i, i)
+		if err := s.StoreCodefile(ctx, testLanguage2, url, []byte(fmt.Sprintf("class F%d {}\n", i)), fmt.Sprintf("c%d", i)); err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+}
+
+func TestSampleFiles(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+	storeSampleTestCodefiles(t, ctx, s)
+
+	options := SampleOptions{Unit: SampleFiles, Seed: 1, StratifyByRepository: true}
+	sample, err := s.Sample(ctx, options)
+	if err != nil {
+		t.Fatalf("Error sampling: %v", err)
+	}
+	if sample.Size != 4 {
+		t.Fatalf("Expected the size of the smallest language, got %d", sample.Size)
+	}
+	for language, count := range sample.Languages {
+		if count.Files != 4 {
+			t.Fatalf("Expected 4 %s files, got %d", language, count.Files)
+		}
+	}
+
+	// stratified, both Python repositories contribute equally
+	repositories := make(map[string]int)
+	for _, file := range sample.Files {
+		if file.Language == testLanguage1.String() {
+			repository, _, _ := ParseRepositoryURL(file.URL)
+			repositories[repository.String()]++
+		}
+	}
+	if repositories["alice/repo0"] != 2 || repositories["alice/repo1"] != 2 {
+		t.Fatalf("Expected 2 files per repository, got %v", repositories)
+	}
+
+	again, err := s.Sample(ctx, options)
+	if err != nil {
+		t.Fatalf("Error sampling: %v", err)
+	}
+	if !reflect.DeepEqual(sample.Files, again.Files) {
+		t.Fatalf("Expected the same sample for the same seed")
+	}
+
+	// the cap leaves 2 Python files, fewer than requested
+	sample, err = s.Sample(ctx, SampleOptions{Unit: SampleFiles, Size: 3, MaxPerRepository: 1})
+	if err != nil {
+		t.Fatalf("Error sampling: %v", err)
+	}
+	if sample.Languages[testLanguage1.String()].Files != 2 || sample.Languages[testLanguage2.String()].Files != 3 {
+		t.Fatalf("Expected 2 Python and 3 C# files, got %+v", sample.Files)
+	}
+}
+
+func TestSampleBytes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+	storeSampleTestCodefiles(t, ctx, s)
+
+	sample, err := s.Sample(ctx, SampleOptions{Unit: SampleBytes, Size: 30, Filter: CodefileFilter{Languages: []Language{testLanguage1}}})
+	if err != nil {
+		t.Fatalf("Error sampling: %v", err)
+	}
+	count := sample.Languages[testLanguage1.String()]
+	if count.Bytes < 30 || count.Bytes >= 30+len("print(10)\n") {
+		t.Fatalf("Expected about 30 bytes, got %d", count.Bytes)
+	}
+	if _, ok := sample.Languages[testLanguage2.String()]; ok {
+		t.Fatalf("Expected no %s files", testLanguage2)
+	}
+
+	if _, err = s.Sample(ctx, SampleOptions{Unit: "lines"}); err == nil {
+		t.Fatalf("Expected error for unknown sample unit")
+	}
+}
+
+func TestExportSample(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+	storeSampleTestCodefiles(t, ctx, s)
+
+	sample, err := s.Sample(ctx, SampleOptions{Unit: SampleTokens, Seed: 3})
+	if err != nil {
+		t.Fatalf("Error sampling: %v", err)
+	}
+
+	target := createTempDatabase(t)
+	defer target.DB.Close()
+
+	e := NewStorageExporter(ctx, target, 2)
+	count, err := s.ExportSample(ctx, sample, e)
+	if err != nil {
+		t.Fatalf("Error exporting sample: %v", err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatalf("Error closing exporter: %v", err)
+	}
+	stored, err := target.CountCodefiles(ctx)
+	if err != nil {
+		t.Fatalf("Error counting codefiles: %v", err)
+	}
+	if count != len(sample.Files) || stored != count {
+		t.Fatalf("Expected %d stored files, got %d", len(sample.Files), stored)
+	}
+
+	var buffer bytes.Buffer
+	if err = sample.WriteManifest(&buffer); err != nil {
+		t.Fatalf("Error writing manifest: %v", err)
+	}
+	var manifest struct {
+		Hashes map[string][]string `json:"hashes"`
+	}
+	if err = json.Unmarshal(buffer.Bytes(), &manifest); err != nil {
+		t.Fatalf("Error reading manifest: %v", err)
+	}
+	if len(manifest.Hashes[testLanguage2.String()]) != sample.Languages[testLanguage2.String()].Files {
+		t.Fatalf("Expected %d hashes, got %v", sample.Languages[testLanguage2.String()].Files, manifest.Hashes)
+	}
+}
@@ -0,0 +1,147 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+const (
+	searchIndexTable = "code_fts"
+
+	sqlCreateSearchIndex = `CREATE VIRTUAL TABLE IF NOT EXISTS "code_fts" USING fts5(content, content='code', content_rowid='id');`
+	// triggers keep the external content index in sync with the code table once it exists
+	sqlCreateSearchTriggers = `CREATE TRIGGER IF NOT EXISTS code_fts_insert AFTER INSERT ON code BEGIN
+	INSERT INTO code_fts(rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS code_fts_delete AFTER DELETE ON code BEGIN
+	INSERT INTO code_fts(code_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+CREATE TRIGGER IF NOT EXISTS code_fts_update AFTER UPDATE OF content ON code BEGIN
+	INSERT INTO code_fts(code_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	INSERT INTO code_fts(rowid, content) VALUES (new.id, new.content);
+END;`
+	sqlRebuildSearchIndex = `INSERT INTO code_fts(code_fts) VALUES ('rebuild');`
+	sqlDropSearchIndex    = `DROP TRIGGER IF EXISTS code_fts_insert; DROP TRIGGER IF EXISTS code_fts_delete; DROP TRIGGER IF EXISTS code_fts_update; DROP TABLE IF EXISTS "code_fts";`
+	sqlSearchCode         = `SELECT code.id, code.language, code.url, code.hash, code.size, snippet(code_fts, 0, ?, ?, ?, ?), bm25(code_fts)
+FROM code_fts JOIN code ON code.id = code_fts.rowid
+WHERE code_fts MATCH ?%s
+ORDER BY bm25(code_fts) LIMIT ? OFFSET ?;`
+)
+
+// DefaultSearchLimit number of search results returned if no limit is given
+const DefaultSearchLimit = 20
+
+var (
+	ErrorNoSearchIndex          = errors.New("no search index, build it first")
+	ErrorSearchIndexUnsupported = errors.New("search index is not supported in blob store mode")
+)
+
+// SearchOptions full-text search parameters, Query uses the SQLite FTS5 query syntax,
+// e.g. `coroutine AND (launch OR async)` or `"suspend fun"`
+type SearchOptions struct {
+	Query          string
+	Languages      []Language // restrict results to these languages, all languages if empty
+	Limit          int
+	Offset         int
+	HighlightStart string // inserted before every match in the snippet
+	HighlightEnd   string // inserted after every match in the snippet
+	SnippetTokens  int    // maximum number of tokens per snippet
+}
+
+// SearchResult a single code file matching the search query
+type SearchResult struct {
+	ID       int64   `json:"id"`
+	Language string  `json:"language"`
+	URL      string  `json:"url"`
+	Hash     string  `json:"hash"`
+	Size     int     `json:"size"`
+	Snippet  string  `json:"snippet"`
+	Rank     float64 `json:"rank"`
+}
+
+// SearchIndexExists reports whether the full-text search index has been built
+func (s Storage) SearchIndexExists(ctx context.Context) (bool, error) {
+	return s.tableExists(ctx, searchIndexTable)
+}
+
+// BuildSearchIndex creates or rebuilds the full-text search index over code.content,
+// afterwards the index is maintained on insert.
+func (s Storage) BuildSearchIndex(ctx context.Context) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	if s.Blobs != nil {
+		return ErrorSearchIndexUnsupported
+	}
+
+	for _, query := range []string{sqlCreateSearchIndex, sqlCreateSearchTriggers, sqlRebuildSearchIndex} {
+		_, err := s.DB.ExecContext(ctx, query)
+		if err != nil {
+			log.Debugf("Failed to execute query [%s]: %s", query, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// DropSearchIndex removes the full-text search index and its triggers
+func (s Storage) DropSearchIndex(ctx context.Context) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	_, err := s.DB.ExecContext(ctx, sqlDropSearchIndex)
+	return err
+}
+
+func (s Storage) Search(ctx context.Context, options SearchOptions) ([]SearchResult, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+
+	exists, err := s.SearchIndexExists(ctx)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, ErrorNoSearchIndex
+	}
+
+	if options.Limit <= 0 {
+		options.Limit = DefaultSearchLimit
+	}
+	if options.SnippetTokens <= 0 {
+		options.SnippetTokens = 16
+	}
+
+	args := []any{options.HighlightStart, options.HighlightEnd, "...", options.SnippetTokens, options.Query}
+	var languageFilter string
+	if len(options.Languages) > 0 {
+		placeholders := make([]string, len(options.Languages))
+		for i, language := range options.Languages {
+			placeholders[i] = "?"
+			args = append(args, language.String())
+		}
+		languageFilter = fmt.Sprintf(" AND code.language IN (%s)", strings.Join(placeholders, ", "))
+	}
+	args = append(args, options.Limit, options.Offset)
+
+	rows, err := s.DB.QueryContext(ctx, fmt.Sprintf(sqlSearchCode, languageFilter), args...)
+	if err != nil {
+		log.Debugf("Failed to search code for \"%s\": %s", options.Query, err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		err = rows.Scan(&r.ID, &r.Language, &r.URL, &r.Hash, &r.Size, &r.Snippet, &r.Rank)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
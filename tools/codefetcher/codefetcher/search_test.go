@@ -0,0 +1,91 @@
+package codefetcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+var (
+	testCodefileKotlin = []byte("import kotlinx.coroutines.*\n\nfun main() = runBlocking {\n    launch { println(\"World\") }\n}\n")
+)
+
+func TestSearchWithoutIndex(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	_, err := s.Search(ctx, SearchOptions{Query: "print"})
+	if err != ErrorNoSearchIndex {
+		t.Fatalf("Expected error %s, got %v", ErrorNoSearchIndex, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	kotlin, _ := ParseLanguage("kotlin")
+
+	// stored before the index exists, picked up by the rebuild
+	err := s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	err = s.BuildSearchIndex(ctx)
+	if err != nil {
+		t.Fatalf("Error building search index: %v", err)
+	}
+
+	// stored after the index exists, picked up by the insert trigger
+	err = s.StoreCodefile(ctx, kotlin, "http://localhost/main.kt", testCodefileKotlin, "")
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	results, err := s.Search(ctx, SearchOptions{Query: "coroutines AND launch", HighlightStart: "[", HighlightEnd: "]"})
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if len(results) != 1 || results[0].URL != "http://localhost/main.kt" {
+		t.Fatalf("Expected main.kt, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "[launch]") {
+		t.Fatalf("Expected highlighted match in snippet, got \"%s\"", results[0].Snippet)
+	}
+
+	results, err = s.Search(ctx, SearchOptions{Query: "print*"})
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	results, err = s.Search(ctx, SearchOptions{Query: "print*", Languages: []Language{testLanguage1}})
+	if err != nil {
+		t.Fatalf("Error searching: %v", err)
+	}
+	if len(results) != 1 || results[0].Language != testLanguage1.String() {
+		t.Fatalf("Expected a single %s result, got %+v", testLanguage1, results)
+	}
+}
+
+func TestSearchIndexBlobMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempBlobDatabase(t)
+	defer s.DB.Close()
+
+	err := s.BuildSearchIndex(ctx)
+	if err != ErrorSearchIndexUnsupported {
+		t.Fatalf("Expected error %s, got %v", ErrorSearchIndexUnsupported, err)
+	}
+}
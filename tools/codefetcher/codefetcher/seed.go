@@ -0,0 +1,169 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sources of seed queries
+const (
+	SeedSourceKeyword    = "keyword"
+	SeedSourceIdentifier = "identifier"
+	SeedSourceCorpus     = "corpus"
+)
+
+// DefaultSeedFiles stored code files scanned by MineSeedTerms
+const DefaultSeedFiles = 1000
+
+var (
+	ErrorNoSeedTerms = errors.New("no seed terms")
+
+	errorSeedFilesScanned = errors.New("seed files scanned")
+)
+
+// SeedVocabulary search terms of a language, searched one after another when no query is given
+type SeedVocabulary struct {
+	Keywords    []string
+	Identifiers []string // common standard library identifiers
+}
+
+// DefaultSeedVocabularies vocabularies of the languages with special handling, by Language.String().
+// Other languages are seeded by the terms mined from the stored code files only.
+var DefaultSeedVocabularies = map[string]SeedVocabulary{
+	string(githubLanguagePython): {
+		Keywords:    []string{"def", "class", "import", "return", "lambda", "yield", "with", "async", "await", "except", "raise", "elif", "assert", "nonlocal"},
+		Identifiers: []string{"self", "__init__", "__name__", "print", "isinstance", "enumerate", "os.path", "sys.argv", "json.loads", "logging", "subprocess", "argparse", "collections", "itertools", "datetime"},
+	},
+	githubLanguageGolang: {
+		Keywords:    []string{"func", "package", "import", "struct", "interface", "chan", "defer", "select", "range", "type"},
+		Identifiers: []string{"fmt.Println", "fmt.Errorf", "errors.New", "context.Context", "http.HandleFunc", "strings.Split", "sync.Mutex", "json.Marshal", "io.Reader", "os.Open", "time.Duration"},
+	},
+	githubLanguageCSharp: {
+		Keywords:    []string{"namespace", "using", "class", "public", "static", "void", "async", "await", "foreach", "override"},
+		Identifiers: []string{"Console.WriteLine", "System.Linq", "List", "Dictionary", "Task", "IEnumerable", "Exception", "DateTime", "StringBuilder", "string.Format"},
+	},
+	githubLanguageCpp: {
+		Keywords:    []string{"template", "typename", "namespace", "class", "public", "virtual", "constexpr", "nullptr", "auto", "override"},
+		Identifiers: []string{"std::vector", "std::string", "std::cout", "std::map", "std::unique_ptr", "std::shared_ptr", "std::move", "std::endl", "size_t", "include"},
+	},
+	githubLanguageC: {
+		Keywords:    []string{"include", "struct", "typedef", "static", "const", "unsigned", "sizeof", "void", "return"},
+		Identifiers: []string{"malloc", "free", "printf", "fprintf", "memcpy", "strlen", "NULL", "stdio.h", "stdlib.h", "size_t"},
+	},
+	githubLanguageJava: {
+		Keywords:    []string{"public", "class", "static", "void", "import", "extends", "implements", "interface", "private", "final"},
+		Identifiers: []string{"System.out.println", "String", "ArrayList", "HashMap", "List", "Override", "Exception", "java.util", "Integer", "StringBuilder"},
+	},
+	githubLanguageJavascript: {
+		Keywords:    []string{"function", "const", "let", "var", "return", "async", "await", "export", "import", "require"},
+		Identifiers: []string{"console.log", "document.getElementById", "module.exports", "Promise", "JSON.stringify", "addEventListener", "setTimeout", "Object.keys", "fetch"},
+	},
+	githubLanguageKotlin: {
+		Keywords:    []string{"fun", "val", "var", "class", "object", "data", "when", "companion", "override", "suspend"},
+		Identifiers: []string{"println", "listOf", "mutableListOf", "String", "Int", "lateinit", "coroutineScope", "apply"},
+	},
+}
+
+// SeedQuery generated search query along with the source of its term
+type SeedQuery struct {
+	Query  string `json:"query"`
+	Source string `json:"source"` // SeedSourceKeyword, SeedSourceIdentifier or SeedSourceCorpus
+}
+
+// seedIdentifier identifiers mined by MineSeedTerms, short ones are too common to narrow a search
+var seedIdentifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{3,}`)
+
+// MineSeedTerms returns the maxTerms identifiers found in most of the first maxFiles stored code
+// files of language, 0 for all files. Ties are sorted by name.
+func MineSeedTerms(ctx context.Context, s Storage, language Language, maxTerms, maxFiles int) ([]string, error) {
+	if maxTerms <= 0 {
+		return nil, nil
+	}
+
+	files := 0
+	documents := make(map[string]int) // number of files by lowercase identifier
+	names := make(map[string]string)  // first spelling of an identifier
+	err := s.IterateCodefiles(ctx, CodefileFilter{Languages: []Language{language}, SkipGenerated: true}, func(c Codefile) error {
+		seen := make(map[string]bool)
+		for _, match := range seedIdentifier.FindAll(c.Content, -1) {
+			key := strings.ToLower(string(match))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			documents[key]++
+			if _, ok := names[key]; !ok {
+				names[key] = string(match)
+			}
+		}
+		files++
+		if maxFiles > 0 && files >= maxFiles {
+			return errorSeedFilesScanned
+		}
+		return ctx.Err()
+	})
+	if err != nil && err != errorSeedFilesScanned {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(documents))
+	for key, count := range documents {
+		if count > 1 { // identifiers of a single file are no seed
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if documents[keys[i]] != documents[keys[j]] {
+			return documents[keys[i]] > documents[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > maxTerms {
+		keys = keys[:maxTerms]
+	}
+
+	terms := make([]string, len(keys))
+	for i, key := range keys {
+		terms[i] = names[key]
+	}
+	return terms, nil
+}
+
+// SeedQueries returns single term queries of language: the keywords of vocabulary, then its
+// identifiers, then the mined terms, see MineSeedTerms. Terms are searched case-insensitive, so
+// duplicates in other cases are dropped. Returns at most maxQueries queries, all if 0.
+func SeedQueries(language Language, vocabulary SeedVocabulary, mined []string, maxQueries int) ([]SeedQuery, error) {
+	var queries []SeedQuery
+	seen := make(map[string]bool)
+	add := func(terms []string, source string) {
+		for _, term := range terms {
+			term = strings.TrimSpace(term)
+			key := strings.ToLower(term)
+			if len(term) == 0 || seen[key] {
+				continue
+			}
+			seen[key] = true
+			queries = append(queries, SeedQuery{Query: quoteSearchValue(term), Source: source})
+		}
+	}
+	add(vocabulary.Keywords, SeedSourceKeyword)
+	add(vocabulary.Identifiers, SeedSourceIdentifier)
+	add(mined, SeedSourceCorpus)
+
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("%w: language %s has no vocabulary and no stored code files", ErrorNoSeedTerms, language)
+	}
+	if maxQueries > 0 && len(queries) > maxQueries {
+		queries = queries[:maxQueries]
+	}
+	for _, q := range queries {
+		if _, err := ParseLanguageSearchQuery(language, q.Query); err != nil {
+			return nil, err
+		}
+	}
+	return queries, nil
+}
@@ -0,0 +1,110 @@
+package codefetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMineSeedTerms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	contents := []string{
+		"import requests\nresponse = requests.get(url)\n",
+		"import requests\nsession = requests.Session()\nresponse = session.get(url)\n",
+		"import Requests\nprint(value)\n",
+		"def only_here(): pass\n",
+	}
+	for i, content := range contents {
+		url := fmt.Sprintf("http://localhost/%d.py", i)
+		if err := s.StoreCodefile(ctx, testLanguage1, url, []byte(content), fmt.Sprintf("%040x", i+1)); err != nil {
+			t.Fatalf("Error storing codefile: %v", err)
+		}
+	}
+
+	terms, err := MineSeedTerms(ctx, s, testLanguage1, 10, 0)
+	if err != nil {
+		t.Fatalf("Error mining seed terms: %v", err)
+	}
+	// "import" and "requests" are in 3 files, "response" in 2, identifiers of a single file are dropped
+	if expected := []string{"import", "requests", "response"}; !reflect.DeepEqual(terms, expected) {
+		t.Fatalf("Expected terms %v, got %v", expected, terms)
+	}
+
+	terms, err = MineSeedTerms(ctx, s, testLanguage1, 1, 0)
+	if err != nil {
+		t.Fatalf("Error mining seed terms: %v", err)
+	}
+	if len(terms) != 1 || terms[0] != "import" {
+		t.Fatalf("Expected term import, got %v", terms)
+	}
+
+	terms, err = MineSeedTerms(ctx, s, testLanguage1, 10, 1)
+	if err != nil {
+		t.Fatalf("Error mining seed terms: %v", err)
+	}
+	if len(terms) != 0 {
+		t.Fatalf("Expected no terms of a single file, got %v", terms)
+	}
+}
+
+func TestSeedQueries(t *testing.T) {
+	vocabulary := SeedVocabulary{
+		Keywords:    []string{"def", "class"},
+		Identifiers: []string{"self", "DEF", "os.path"},
+	}
+	queries, err := SeedQueries(testLanguage1, vocabulary, []string{"Self", "http client"}, 0)
+	if err != nil {
+		t.Fatalf("Error generating seed queries: %v", err)
+	}
+	expected := []SeedQuery{
+		{Query: "def", Source: SeedSourceKeyword},
+		{Query: "class", Source: SeedSourceKeyword},
+		{Query: "self", Source: SeedSourceIdentifier},
+		{Query: "os.path", Source: SeedSourceIdentifier},
+		{Query: `"http client"`, Source: SeedSourceCorpus},
+	}
+	if !reflect.DeepEqual(queries, expected) {
+		t.Fatalf("Expected queries %v, got %v", expected, queries)
+	}
+
+	queries, err = SeedQueries(testLanguage1, vocabulary, nil, 2)
+	if err != nil {
+		t.Fatalf("Error generating seed queries: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("Expected 2 queries, got %v", queries)
+	}
+
+	if _, err := SeedQueries(testLanguage1, SeedVocabulary{}, []string{" "}, 0); !errors.Is(err, ErrorNoSeedTerms) {
+		t.Fatalf("Expected error %v, got %v", ErrorNoSeedTerms, err)
+	}
+}
+
+func TestFetchQueriesSizeLimitReached(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	if err := s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash); err != nil {
+		t.Fatalf("Error storing codefile: %v", err)
+	}
+
+	// the limit is reached before the first search request
+	fetcher := NewGithubFetcher("", "", s, 0)
+	summary, err := fetcher.FetchQueries(ctx, testLanguage1, []string{"def", "class"}, 1)
+	if err != nil {
+		t.Fatalf("Error fetching queries: %v", err)
+	}
+	if summary.Stored != 0 {
+		t.Fatalf("Expected no stored files, got %+v", summary)
+	}
+}
@@ -0,0 +1,228 @@
+package codefetcher
+
+import (
+	"context"
+	"database/sql"
+	log "github.com/sirupsen/logrus"
+	"hash/fnv"
+	"strconv"
+)
+
+// splitTarget a database receiving a part of a split, along with its report
+type splitTarget struct {
+	storage Storage
+	report  MergeReport
+}
+
+// splitMetadata tables copied to every target of a split, so a target that continues crawling
+// skips purged and blocked files and assigns splits like the source
+type splitMetadata struct {
+	config     SplitConfig
+	tombstones []Tombstone
+	blocklist  Blocklist
+}
+
+func (s Storage) getSplitMetadata(ctx context.Context) (splitMetadata, error) {
+	var m splitMetadata
+	var err error
+	if m.config, err = s.GetSplitConfig(ctx); err != nil {
+		return m, err
+	}
+	if m.tombstones, err = s.ListTombstones(ctx); err != nil {
+		return m, err
+	}
+	m.blocklist, err = s.GetBlocklist(ctx)
+	return m, err
+}
+
+// copyTo writes the metadata to target and counts the tombstones in report
+func (m splitMetadata) copyTo(ctx context.Context, target Storage, report *MergeReport) error {
+	if err := target.SetSplitConfig(ctx, m.config); err != nil {
+		return err
+	}
+	for _, t := range m.tombstones {
+		if err := insertTombstone(ctx, target.DB, t); err != nil {
+			return err
+		}
+		report.Tombstones++
+	}
+	return target.AddBlocklist(ctx, m.blocklist)
+}
+
+// SplitByLanguage writes the code files and progress of every language to a database of
+// its own, open is called once per language to create the target. Tombstones, the split
+// config and the blocklist are copied to every target. Returns the reports by language.
+func (s Storage) SplitByLanguage(ctx context.Context, open func(language string) (Storage, error), batchSize int) (map[string]MergeReport, error) {
+	targets := make(map[string]splitTarget)
+	defer func() {
+		for _, t := range targets {
+			t.storage.DB.Close()
+		}
+	}()
+
+	metadata, err := s.getSplitMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	target := func(language string) (splitTarget, error) {
+		if t, ok := targets[language]; ok {
+			return t, nil
+		}
+		storage, err := open(language)
+		if err != nil {
+			return splitTarget{}, err
+		}
+		// registered before the copy, so the target is closed on errors as well
+		t := splitTarget{storage: storage, report: MergeReport{Languages: make(map[string]*MergeCount)}}
+		targets[language] = t
+		if err = metadata.copyTo(ctx, storage, &t.report); err != nil {
+			return splitTarget{}, err
+		}
+		targets[language] = t
+		return t, nil
+	}
+
+	reports := func() map[string]MergeReport {
+		r := make(map[string]MergeReport)
+		for language, t := range targets {
+			r[language] = t.report
+		}
+		return r
+	}
+
+	err = s.split(ctx, batchSize, func(c Codefile) (splitTarget, error) { return target(c.Language) })
+	if err != nil {
+		return reports(), err
+	}
+
+	progress, err := s.ListProgress(ctx)
+	if err != nil {
+		return reports(), err
+	}
+	for _, p := range progress {
+		t, err := target(p.Language)
+		if err != nil {
+			return reports(), err
+		}
+		if err = t.storage.mergeProgress(ctx, p); err != nil {
+			return reports(), err
+		}
+		t.report.Progress++
+		targets[p.Language] = t
+	}
+	return reports(), nil
+}
+
+// SplitByHash distributes the code files over the given shards by hash range, so every
+// shard holds a contiguous range of hashes. Progress, tombstones, the split config and the
+// blocklist are copied to every shard.
+func (s Storage) SplitByHash(ctx context.Context, shards []Storage, batchSize int) ([]MergeReport, error) {
+	reports := make([]MergeReport, len(shards))
+	for i := range reports {
+		reports[i] = MergeReport{Languages: make(map[string]*MergeCount)}
+	}
+
+	metadata, err := s.getSplitMetadata(ctx)
+	if err != nil {
+		return reports, err
+	}
+	for i, shard := range shards {
+		if err = metadata.copyTo(ctx, shard, &reports[i]); err != nil {
+			return reports, err
+		}
+	}
+
+	err = s.split(ctx, batchSize, func(c Codefile) (splitTarget, error) {
+		i := HashShard(c.Hash, len(shards))
+		return splitTarget{storage: shards[i], report: reports[i]}, nil
+	})
+	if err != nil {
+		return reports, err
+	}
+
+	progress, err := s.ListProgress(ctx)
+	if err != nil {
+		return reports, err
+	}
+	for i, shard := range shards {
+		for _, p := range progress {
+			if err = shard.mergeProgress(ctx, p); err != nil {
+				return reports, err
+			}
+			reports[i].Progress++
+		}
+	}
+	return reports, nil
+}
+
+// split reads all code files in batches and writes each to the target returned by route,
+// the files keep the run that fetched them
+func (s Storage) split(ctx context.Context, batchSize int, route func(c Codefile) (splitTarget, error)) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultMergeBatchSize
+	}
+
+	// the runs are copied to a target along with its first batch
+	runs := make(map[*sql.DB]map[int64]int64)
+	var cursor int64
+	for {
+		codefiles, next, err := s.ListCodefiles(ctx, CodefileFilter{}, cursor, batchSize)
+		if err != nil {
+			return err
+		}
+
+		targets := make(map[*sql.DB]splitTarget)
+		batches := make(map[*sql.DB][]Codefile)
+		for _, c := range codefiles {
+			t, err := route(c)
+			if err != nil {
+				return err
+			}
+			targets[t.storage.DB] = t
+			batches[t.storage.DB] = append(batches[t.storage.DB], c)
+		}
+		for db, batch := range batches {
+			t := targets[db]
+			if _, ok := runs[db]; !ok {
+				if runs[db], err = t.storage.copyRuns(ctx, s); err != nil {
+					return err
+				}
+			}
+			remapRuns(batch, runs[db])
+			if err = t.storage.mergeCodefiles(ctx, batch, t.report); err != nil {
+				return err
+			}
+		}
+		log.Debugf("Split %d codefiles", len(codefiles))
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// HashShard returns the shard of n a hash belongs to. Hex hashes are assigned by range of
+// their first 8 digits, any other hash by its FNV-1a checksum.
+func HashShard(hash string, n int) int {
+	if n <= 1 {
+		return 0
+	}
+
+	var value uint64
+	parsed := false
+	if len(hash) >= 8 {
+		if v, err := strconv.ParseUint(hash[:8], 16, 32); err == nil {
+			value, parsed = v, true
+		}
+	}
+	if !parsed {
+		h := fnv.New32a()
+		h.Write([]byte(hash))
+		value = uint64(h.Sum32())
+	}
+	return int(value * uint64(n) >> 32)
+}
@@ -0,0 +1,158 @@
+package codefetcher
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHashShard(t *testing.T) {
+	tests := []struct {
+		hash     string
+		n        int
+		expected int
+	}{
+		{"00000000aaaa", 4, 0},
+		{"3fffffffaaaa", 4, 0},
+		{"40000000aaaa", 4, 1},
+		{"bfffffffaaaa", 4, 2},
+		{"ffffffffaaaa", 4, 3},
+		{"ffffffffaaaa", 1, 0},
+	}
+	for _, test := range tests {
+		if shard := HashShard(test.hash, test.n); shard != test.expected {
+			t.Errorf("Expected hash %s in shard %d of %d, got %d", test.hash, test.expected, test.n, shard)
+		}
+	}
+
+	if shard := HashShard("not-a-hex-hash", 4); shard < 0 || shard >= 4 {
+		t.Errorf("Expected shard in range [0, 4), got %d", shard)
+	}
+}
+
+func TestSplitByLanguage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	storeTestCodefiles(t, ctx, s, testLanguage1, 5)
+	storeTestCodefiles(t, ctx, s, testLanguage2, 3)
+	if err := s.UpdateProgress(ctx, testLanguage1, "*", -1); err != nil {
+		t.Fatalf("Error updating progress: %v", err)
+	}
+	if err := s.UpdateProgress(ctx, testLanguage2, "*", 7); err != nil {
+		t.Fatalf("Error updating progress: %v", err)
+	}
+	config := SplitConfig{Train: 1, Seed: 3}
+	if err := s.SetSplitConfig(ctx, config); err != nil {
+		t.Fatalf("Error setting split config: %v", err)
+	}
+
+	paths := make(map[string]string)
+	reports, err := s.SplitByLanguage(ctx, func(language string) (Storage, error) {
+		paths[language] = t.TempDir() + testDatabasePath
+		return openTestDatabase(t, paths[language]), nil
+	}, 2)
+	if err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+
+	expected := []struct {
+		language Language
+		count    int
+	}{{testLanguage1, 5}, {testLanguage2, 3}}
+	if len(reports) != len(expected) {
+		t.Fatalf("Expected %d databases, got %d", len(expected), len(reports))
+	}
+	for _, e := range expected {
+		language, count := e.language, e.count
+		report := reports[language.String()]
+		if report.Languages[language.String()].Inserted != count || report.Progress != 1 {
+			t.Errorf("Expected %d %s files and 1 progress row, got %+v", count, language, report)
+		}
+
+		// reopen the closed split database like a new crawl would
+		target := openTestDatabase(t, paths[language.String()])
+		size, err := target.GetTotalCodeSizeByLanguage(ctx, language)
+		if err != nil {
+			t.Fatalf("Error querying database: %v", err)
+		}
+		expectedSize, _ := s.GetTotalCodeSizeByLanguage(ctx, language)
+		if size != expectedSize {
+			t.Errorf("Expected %d bytes of %s, got %d", expectedSize, language, size)
+		}
+		if c, err := target.GetSplitConfig(ctx); err != nil || c != config {
+			t.Errorf("Expected split config %+v for %s, got %+v (%v)", config, language, c, err)
+		}
+		target.DB.Close()
+	}
+}
+
+func TestSplitByHash(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	storeTestCodefiles(t, ctx, s, testLanguage1, 20)
+	if err := s.UpdateProgress(ctx, testLanguage1, "*", 3); err != nil {
+		t.Fatalf("Error updating progress: %v", err)
+	}
+	config := SplitConfig{Train: 0.5, Test: 0.5, Seed: 7}
+	if err := s.SetSplitConfig(ctx, config); err != nil {
+		t.Fatalf("Error setting split config: %v", err)
+	}
+	tombstone := Tombstone{Hash: "purged", URL: "https://github.com/bob/tools/blob/main/a.py", PurgedAt: "2024-01-01T00:00:00Z"}
+	if err := insertTombstone(ctx, s.DB, tombstone); err != nil {
+		t.Fatalf("Error inserting tombstone: %v", err)
+	}
+	blocklist := Blocklist{{Kind: BlockOwner, Pattern: "bob"}}
+	if err := s.AddBlocklist(ctx, blocklist); err != nil {
+		t.Fatalf("Error adding blocklist: %v", err)
+	}
+
+	shards := []Storage{createTempDatabase(t), createTempDatabase(t), createTempDatabase(t)}
+	reports, err := s.SplitByHash(ctx, shards, 4)
+	if err != nil {
+		t.Fatalf("Error splitting: %v", err)
+	}
+
+	var total int
+	for i, shard := range shards {
+		err = shard.IterateCodefiles(ctx, CodefileFilter{SkipContent: true}, func(c Codefile) error {
+			if HashShard(c.Hash, len(shards)) != i {
+				t.Errorf("Codefile %s in wrong shard %d", c.Hash, i)
+			}
+			total++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Error iterating codefiles: %v", err)
+		}
+
+		progress, err := shard.GetProgress(ctx, testLanguage1, "*")
+		if err != nil {
+			t.Fatalf("Error getting progress: %v", err)
+		}
+		if progress != 3 || reports[i].Progress != 1 {
+			t.Errorf("Expected progress 3 in shard %d, got %d", i, progress)
+		}
+
+		// a shard continuing the crawl skips purged and blocked files and assigns the same splits
+		if c, err := shard.GetSplitConfig(ctx); err != nil || c != config {
+			t.Errorf("Expected split config %+v in shard %d, got %+v (%v)", config, i, c, err)
+		}
+		if exists, err := shard.TombstoneExists(ctx, tombstone.Hash); err != nil || !exists || reports[i].Tombstones != 1 {
+			t.Errorf("Expected tombstone in shard %d, got %v (%v)", i, exists, err)
+		}
+		if b, err := shard.GetBlocklist(ctx); err != nil || len(b) != 1 || b[0].Pattern != "bob" {
+			t.Errorf("Expected blocklist %v in shard %d, got %v (%v)", blocklist, i, b, err)
+		}
+		shard.DB.Close()
+	}
+	if total != 20 {
+		t.Fatalf("Expected 20 codefiles in all shards, got %d", total)
+	}
+}
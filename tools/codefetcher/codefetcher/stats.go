@@ -0,0 +1,228 @@
+package codefetcher
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"unicode"
+)
+
+// statsSizeBuckets upper bounds of the size histogram in bytes, the last bucket is open-ended
+var statsSizeBuckets = []int{1024, 4 * 1024, 16 * 1024, 64 * 1024, CodeSizeLimit}
+
+// DefaultStatsTopN number of top repositories and owners reported per language
+const DefaultStatsTopN = 10
+
+// HistogramBucket number of files up to UpperBound bytes, UpperBound is 0 for the open-ended last bucket
+type HistogramBucket struct {
+	UpperBound int `json:"upper_bound"`
+	Count      int `json:"count"`
+}
+
+// Distribution summary of an integer distribution
+type Distribution struct {
+	Min    int     `json:"min"`
+	P25    int     `json:"p25"`
+	Median int     `json:"median"`
+	P75    int     `json:"p75"`
+	P90    int     `json:"p90"`
+	Max    int     `json:"max"`
+	Mean   float64 `json:"mean"`
+}
+
+// RankedCount number of files of a repository or owner
+type RankedCount struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+}
+
+// LanguageStats corpus statistics of a single language
+type LanguageStats struct {
+	Language        string            `json:"language"`
+	Files           int               `json:"files"`
+	TotalBytes      int               `json:"total_bytes"`
+	MedianBytes     int               `json:"median_bytes"`
+	SizeHistogram   []HistogramBucket `json:"size_histogram"`
+	Lines           Distribution      `json:"lines"`
+	TopRepositories []RankedCount     `json:"top_repositories"`
+	TopOwners       []RankedCount     `json:"top_owners"`
+	Splits          map[string]int    `json:"splits"`                   // number of files by dataset split, "" for unassigned files
+	DuplicateRate   float64           `json:"duplicate_rate"`           // fraction of files whose content differs from another file only in whitespace
+	TargetReached   float64           `json:"target_reached,omitempty"` // percentage of StatsOptions.MaxCodeSize
+}
+
+// StatsOptions parameters of Storage.Stats
+type StatsOptions struct {
+	Languages     []Language // all languages if empty
+	Splits        []string   // only files of these dataset splits, all if empty
+	SkipGenerated bool       // skip files tagged as generated
+	TopN          int        // number of top repositories and owners
+	MaxCodeSize   int        // size target per language in bytes, 0 if there is none
+}
+
+// languageStatsCollector accumulates the statistics of a language while iterating the corpus
+type languageStatsCollector struct {
+	sizes        []int
+	lines        []int
+	repositories map[string]int
+	owners       map[string]int
+	splits       map[string]int
+	normalized   map[uint64]bool
+	duplicates   int
+}
+
+func newLanguageStatsCollector() *languageStatsCollector {
+	return &languageStatsCollector{
+		repositories: make(map[string]int),
+		owners:       make(map[string]int),
+		splits:       make(map[string]int),
+		normalized:   make(map[uint64]bool),
+	}
+}
+
+func (c *languageStatsCollector) add(codefile Codefile) {
+	c.sizes = append(c.sizes, codefile.Size)
+	c.lines = append(c.lines, CountLines(codefile.Content))
+	c.splits[codefile.Split]++
+
+	if repository, _, err := ParseRepositoryURL(codefile.URL); err == nil {
+		c.repositories[repository.String()]++
+		c.owners[repository.Owner]++
+	}
+
+	h := normalizedContentHash(codefile.Content)
+	if c.normalized[h] {
+		c.duplicates++
+	}
+	c.normalized[h] = true
+}
+
+func (c *languageStatsCollector) stats(language string, options StatsOptions) LanguageStats {
+	stats := LanguageStats{
+		Language:        language,
+		Files:           len(c.sizes),
+		Lines:           distribution(c.lines),
+		TopRepositories: topCounts(c.repositories, options.TopN),
+		TopOwners:       topCounts(c.owners, options.TopN),
+		Splits:          c.splits,
+	}
+
+	for _, size := range c.sizes {
+		stats.TotalBytes += size
+	}
+	stats.MedianBytes = distribution(c.sizes).Median
+
+	stats.SizeHistogram = make([]HistogramBucket, len(statsSizeBuckets)+1)
+	for i, bound := range statsSizeBuckets {
+		stats.SizeHistogram[i].UpperBound = bound
+	}
+	for _, size := range c.sizes {
+		i := sort.SearchInts(statsSizeBuckets, size)
+		stats.SizeHistogram[i].Count++
+	}
+
+	if stats.Files > 0 {
+		stats.DuplicateRate = float64(c.duplicates) / float64(stats.Files)
+	}
+	if options.MaxCodeSize > 0 {
+		stats.TargetReached = 100 * float64(stats.TotalBytes) / float64(options.MaxCodeSize)
+	}
+	return stats
+}
+
+// Stats computes corpus statistics per language, ordered by language name
+func (s Storage) Stats(ctx context.Context, options StatsOptions) ([]LanguageStats, error) {
+	if options.TopN <= 0 {
+		options.TopN = DefaultStatsTopN
+	}
+
+	collectors := make(map[string]*languageStatsCollector)
+	err := s.IterateCodefiles(ctx, CodefileFilter{Languages: options.Languages, Splits: options.Splits, SkipGenerated: options.SkipGenerated}, func(c Codefile) error {
+		collector, ok := collectors[c.Language]
+		if !ok {
+			collector = newLanguageStatsCollector()
+			collectors[c.Language] = collector
+		}
+		collector.add(c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []LanguageStats
+	for language, collector := range collectors {
+		stats = append(stats, collector.stats(language, options))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Language < stats[j].Language })
+	return stats, nil
+}
+
+// CountLines returns the number of lines of content, a missing final newline still counts as a line
+func CountLines(content []byte) int {
+	if len(content) == 0 {
+		return 0
+	}
+	lines := bytes.Count(content, []byte{'\n'})
+	if content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines
+}
+
+// normalizedContentHash hashes content with all whitespace removed
+func normalizedContentHash(content []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(bytes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, content))
+	return h.Sum64()
+}
+
+func distribution(values []int) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]int{}, values...)
+	sort.Ints(sorted)
+
+	percentile := func(p float64) int {
+		return sorted[int(math.Round(p*float64(len(sorted)-1)))]
+	}
+
+	var sum int
+	for _, v := range sorted {
+		sum += v
+	}
+	return Distribution{
+		Min:    sorted[0],
+		P25:    percentile(0.25),
+		Median: percentile(0.5),
+		P75:    percentile(0.75),
+		P90:    percentile(0.9),
+		Max:    sorted[len(sorted)-1],
+		Mean:   float64(sum) / float64(len(sorted)),
+	}
+}
+
+func topCounts(counts map[string]int, n int) []RankedCount {
+	ranked := make([]RankedCount, 0, len(counts))
+	for name, files := range counts {
+		ranked = append(ranked, RankedCount{Name: name, Files: files})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Files != ranked[j].Files {
+			return ranked[i].Files > ranked[j].Files
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	if len(ranked) > n {
+		ranked = ranked[:n]
+	}
+	return ranked
+}
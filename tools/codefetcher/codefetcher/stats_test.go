@@ -0,0 +1,96 @@
+package codefetcher
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"a\n", 1},
+		{"a\nb", 2},
+		{"a\n\nb\n", 3},
+	}
+	for _, test := range tests {
+		if lines := CountLines([]byte(test.content)); lines != test.expected {
+			t.Errorf("Expected %d lines for %q, got %d", test.expected, test.content, lines)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	files := []struct {
+		url     string
+		content string
+		hash    string
+	}{
+		{"https://github.com/alice/tools/blob/0123/a.py", "print(1)\n", "a1"},
+		{"https://github.com/alice/tools/blob/0123/b.py", "print( 1 )\n\n", "a2"}, // whitespace duplicate of a.py
+		{"https://github.com/alice/web/blob/0123/c.py", "x = 1\ny = 2\nprint(x + y)\n", "a3"},
+		{"https://github.com/bob/tools/blob/0123/d.py", string(make([]byte, 2048)), "a4"},
+	}
+	for _, f := range files {
+		if err := s.StoreCodefile(ctx, testLanguage1, f.url, []byte(f.content), f.hash); err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+	if err := s.StoreCodefile(ctx, testLanguage2, "https://github.com/bob/cs/blob/0123/e.cs", []byte("class A {}\n"), "a5"); err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	var totalBytes int
+	for _, f := range files {
+		totalBytes += len(f.content)
+	}
+
+	stats, err := s.Stats(ctx, StatsOptions{Languages: []Language{testLanguage1}, TopN: 1, MaxCodeSize: 2 * totalBytes})
+	if err != nil {
+		t.Fatalf("Error computing stats: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("Expected stats of 1 language, got %d", len(stats))
+	}
+
+	python := stats[0]
+	if python.Language != testLanguage1.String() || python.Files != 4 || python.TotalBytes != totalBytes {
+		t.Fatalf("Unexpected stats %+v", python)
+	}
+	if python.DuplicateRate != 0.25 {
+		t.Errorf("Expected duplicate rate 0.25, got %f", python.DuplicateRate)
+	}
+	if math.Abs(python.TargetReached-50) > 0.001 {
+		t.Errorf("Expected 50%% of target reached, got %f", python.TargetReached)
+	}
+	if len(python.TopOwners) != 1 || python.TopOwners[0] != (RankedCount{"alice", 3}) {
+		t.Errorf("Expected top owner alice with 3 files, got %v", python.TopOwners)
+	}
+	if len(python.TopRepositories) != 1 || python.TopRepositories[0] != (RankedCount{"alice/tools", 2}) {
+		t.Errorf("Expected top repository alice/tools with 2 files, got %v", python.TopRepositories)
+	}
+	if python.Lines.Min != 1 || python.Lines.Max != 3 {
+		t.Errorf("Expected 1 to 3 lines, got %+v", python.Lines)
+	}
+	if python.SizeHistogram[0].Count != 3 || python.SizeHistogram[1].Count != 1 {
+		t.Errorf("Unexpected size histogram %v", python.SizeHistogram)
+	}
+
+	stats, err = s.Stats(ctx, StatsOptions{})
+	if err != nil {
+		t.Fatalf("Error computing stats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Language != testLanguage2.String() {
+		t.Fatalf("Expected stats of 2 languages ordered by name, got %+v", stats)
+	}
+}
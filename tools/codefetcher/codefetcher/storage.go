@@ -6,9 +6,11 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
-	"github.com/glebarez/go-sqlite"
 	_ "github.com/glebarez/go-sqlite"
 	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"time"
 )
 
 const (
@@ -27,32 +29,122 @@ const (
     	"last_page"	INTEGER NOT NULL DEFAULT 0,
     	PRIMARY KEY("language", "query")
 );`
-	sqlDropTables            = `DROP TABLE IF EXISTS "code"; DROP TABLE IF EXISTS "progress";`
-	sqlInsertCode            = `INSERT INTO code (language, url, content, hash, size) VALUES (?, ?, ?, ?, ?)`
+	sqlDropTables            = `DROP TABLE IF EXISTS "code"; DROP TABLE IF EXISTS "progress"; DROP TABLE IF EXISTS "split_config"; DROP TABLE IF EXISTS "runs"; DROP TABLE IF EXISTS "blocklist"; DROP TABLE IF EXISTS "tombstones";`
+	sqlInsertCode            = `INSERT INTO code (language, url, content, hash, size, blob, source, split, run_id, merge_run_id, generated) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (hash) DO NOTHING;`
 	sqlCountCodes            = `SELECT COUNT(id) as row_count FROM code;`
-	sqlGetFirstFromTable     = `SELECT * FROM %s LIMIT 1;`
+	sqlTableExists           = `SELECT COUNT(name) FROM sqlite_schema WHERE type = 'table' AND name = ?;`
+	sqlColumnExists          = `SELECT COUNT(name) FROM pragma_table_info(?) WHERE name = ?;`
+	sqlAddColumn             = `ALTER TABLE "%s" ADD COLUMN "%s" %s;`
 	sqlGetCodeSizeByLanguage = `SELECT IFNULL(SUM(size), 0) as total_size FROM code WHERE language = ?;`
 	sqlCodeExists            = `SELECT COUNT(1) FROM code WHERE hash = ?;`
+	sqlCountCodesByURL       = `SELECT COUNT(id) FROM code WHERE url LIKE ? ESCAPE '\';`
+	sqlGetCodeSizeByURL      = `SELECT IFNULL(SUM(size), 0) FROM code WHERE url LIKE ? ESCAPE '\';`
 	sqlGetProgress           = `SELECT last_page FROM progress WHERE language = ? AND query = ?;`
 	sqlUpdateProgress        = `INSERT OR REPLACE INTO progress (language, query, last_page) VALUES (?, ?, ?);`
+	sqlListProgress          = `SELECT language, query, last_page FROM progress ORDER BY language, query;`
+	sqlGetBlobKeys           = `SELECT blob FROM code WHERE blob IS NOT NULL;`
+	sqlDeleteCodeByBlob      = `DELETE FROM code WHERE blob = ?;`
 )
 
+// columnMigrations columns added after the initial schema, applied to existing databases by Init
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"code", "blob", "TEXT"},
+	{"code", "source", "TEXT NOT NULL DEFAULT '" + SourceGithub + "'"},
+	{"code", "split", "TEXT"},
+	{"code", "run_id", "INTEGER"},
+	{"code", "generated", "INTEGER"},
+	{"code", "merge_run_id", "INTEGER"},
+	{"runs", "mismatches", "TEXT NOT NULL DEFAULT '{}'"},
+}
+
+// SourceGithub source of code files fetched by GithubFetcher
+const SourceGithub = "github"
+
 var (
-	ErrorNoDatabase = fmt.Errorf("no database initialized")
+	ErrorNoDatabase  = fmt.Errorf("no database initialized")
+	ErrorNoBlobStore = fmt.Errorf("no blob store configured")
+	ErrorOldSchema   = fmt.Errorf("database schema is outdated")
 )
 
+// schemaTables tables created by Init
+var schemaTables = []string{"code", "progress", "split_config", "runs", "blocklist", "tombstones"}
+
+// Backend storage operations needed by GithubFetcher
+type Backend interface {
+	StoreCodefile(ctx context.Context, language Language, url string, content []byte, hash string) error
+	CodeExistsByHash(ctx context.Context, hash string) (bool, error)
+	GetTotalCodeSizeByLanguage(ctx context.Context, language Language) (int, error)
+	GetProgress(ctx context.Context, language Language, query string) (int, error)
+	UpdateProgress(ctx context.Context, language Language, query string, lastPage int) error
+	CountCodefilesByRepository(ctx context.Context, repository Repository) (int, error)
+	GetTotalCodeSizeByOwner(ctx context.Context, owner string) (int, error)
+	TombstoneExists(ctx context.Context, hash string) (bool, error)
+}
+
+// Storage stores code files in SQLite. If Blobs is set, file contents are written
+// to the blob store and the code table only holds the metadata and the blob key,
+// which keeps dedupe and size totals local while the contents live on disk or in S3.
+// Inserted code files are tagged with RunID, see StartRun. Merged code files keep the run
+// that fetched them and record RunID as their merge run.
 type Storage struct {
-	DB *sql.DB
+	DB          *sql.DB
+	Blobs       BlobStore
+	RunID       int64
+	SplitConfig *SplitConfig // assigns the split of stored code files, read from the database per insert if nil
 }
 
 func (s Storage) Init(ctx context.Context) error {
-	for _, query := range []string{sqlCreateTableCode, sqlCreateTableProgress} {
+	for _, query := range []string{sqlCreateTableCode, sqlCreateTableProgress, sqlCreateTableSplitConfig, sqlCreateTableRuns, sqlCreateTableBlocklist, sqlCreateTableTombstones} {
 		_, err := s.DB.ExecContext(ctx, query)
 		if err != nil {
 			log.Debugf("Failed to execute query [%s]: %s", query, err.Error())
 			return err
 		}
 	}
+
+	for _, migration := range columnMigrations {
+		exists, err := s.columnExists(ctx, migration.table, migration.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		query := fmt.Sprintf(sqlAddColumn, migration.table, migration.column, migration.definition)
+		_, err = s.DB.ExecContext(ctx, query)
+		if err != nil {
+			log.Debugf("Failed to execute query [%s]: %s", query, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSchema returns ErrorOldSchema if a table or column created by Init is missing. Databases
+// that are only read, e.g. the sources of Merge, are checked instead of being migrated.
+func (s Storage) CheckSchema(ctx context.Context) error {
+	for _, table := range schemaTables {
+		exists, err := s.tableExists(ctx, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: missing table %s", ErrorOldSchema, table)
+		}
+	}
+	for _, migration := range columnMigrations {
+		exists, err := s.columnExists(ctx, migration.table, migration.column)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: missing column %s.%s", ErrorOldSchema, migration.table, migration.column)
+		}
+	}
 	return nil
 }
 
@@ -65,18 +157,99 @@ func (s Storage) StoreCodefile(ctx context.Context, language Language, url strin
 		hash = hex.EncodeToString(sha1.New().Sum(content))
 	}
 
-	_, err := s.DB.ExecContext(ctx, sqlInsertCode, language.String(), url, content, hash, len(content))
+	config, err := s.splitConfig(ctx, s.DB)
 	if err != nil {
-		if errSql, ok := err.(*sqlite.Error); ok {
-			if errSql.Code() == 2067 {
-				// threat duplicate hashes as already stored
-				return nil
+		return err
+	}
+
+	// duplicate hashes are treated as already stored
+	_, err = s.insertCodefile(ctx, s.DB, config, Codefile{Language: language.String(), URL: url, Content: content, Hash: hash, Size: len(content), Source: SourceGithub})
+	return err
+}
+
+// execer is implemented by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// splitConfig returns s.SplitConfig or else the split config stored in db
+func (s Storage) splitConfig(ctx context.Context, db execer) (SplitConfig, error) {
+	if s.SplitConfig != nil {
+		return *s.SplitConfig, nil
+	}
+	return getSplitConfig(ctx, db)
+}
+
+// insertCodefile inserts c, in blob mode the content is written to the blob store and only
+// the blob key is kept in the database. Code files without split are assigned one by config
+// and the generated tag is set by DetectGenerated unless c is tagged already, so the
+// assignments of merged databases are kept. Returns false if the hash is already stored.
+func (s Storage) insertCodefile(ctx context.Context, db execer, config SplitConfig, c Codefile) (bool, error) {
+	// duplicates must not overwrite the blob metadata of the stored code file
+	var exists bool
+	if err := db.QueryRowContext(ctx, sqlCodeExists, c.Hash).Scan(&exists); err != nil {
+		log.Debugf("Failed to check if code exists: %s", err.Error())
+		return false, err
+	} else if exists {
+		return false, nil
+	}
+
+	split := c.Split
+	if len(split) == 0 {
+		split = config.Assign(c.URL)
+	}
+	generated := c.Generated || len(DetectGenerated(c.URL, c.Content)) > 0
+
+	var blob any
+	content := c.Content
+	if s.Blobs != nil {
+		if err := s.Blobs.Put(ctx, c.Hash, content); err != nil {
+			log.Debugf("Failed to save blob %s: %s", c.Hash, err.Error())
+			return false, err
+		}
+		if w, ok := s.Blobs.(BlobMetadataWriter); ok {
+			metadata := BlobMetadata{
+				Hash:      c.Hash,
+				Language:  c.Language,
+				URL:       c.URL,
+				Size:      c.Size,
+				FetchedAt: time.Now().UTC().Format(time.RFC3339),
+			}
+			if err := w.PutMetadata(ctx, c.Hash, metadata); err != nil {
+				log.Debugf("Failed to save blob metadata %s: %s", c.Hash, err.Error())
+				return false, err
 			}
 		}
-		log.Debugf("Failed to save codefile VALUES(%s, %s): %s", language.String(), url, err.Error())
-		return err
+		blob, content = c.Hash, []byte{}
 	}
-	return nil
+
+	source := c.Source
+	if len(source) == 0 {
+		source = SourceGithub
+	}
+
+	// merged rows keep the run that fetched them, the merging run is recorded separately
+	var runID, mergeRunID any
+	if c.RunID > 0 {
+		runID = c.RunID
+		if s.RunID > 0 {
+			mergeRunID = s.RunID
+		}
+	} else if s.RunID > 0 {
+		runID = s.RunID
+	}
+
+	result, err := db.ExecContext(ctx, sqlInsertCode, c.Language, c.URL, content, c.Hash, c.Size, blob, source, split, runID, mergeRunID, generated)
+	if err != nil {
+		log.Debugf("Failed to save codefile VALUES(%s, %s): %s", c.Language, c.URL, err.Error())
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
 }
 
 func (s Storage) CountCodefiles(ctx context.Context) (int, error) {
@@ -105,6 +278,39 @@ func (s Storage) GetTotalCodeSizeByLanguage(ctx context.Context, language Langua
 	return size, nil
 }
 
+// CountCodefilesByRepository returns the number of stored code files of a GitHub repository
+func (s Storage) CountCodefilesByRepository(ctx context.Context, repository Repository) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var count int
+	err := s.DB.QueryRowContext(ctx, sqlCountCodesByURL, likePrefix(repository.URL()+"/")).Scan(&count)
+	if err != nil {
+		log.Debugf("Failed to count codefiles of repository %s: %s", repository, err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetTotalCodeSizeByOwner returns the total code size of all repositories of a GitHub user or organization
+func (s Storage) GetTotalCodeSizeByOwner(ctx context.Context, owner string) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var size int
+	err := s.DB.QueryRowContext(ctx, sqlGetCodeSizeByURL, likePrefix(githubURL+owner+"/")).Scan(&size)
+	if err != nil {
+		log.Debugf("Failed to get total code size of owner %s: %s", owner, err.Error())
+		return 0, err
+	}
+	return size, nil
+}
+
+// likePrefix returns a LIKE pattern matching all strings starting with prefix, escaped with '\'
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
 func (s Storage) GetProgress(ctx context.Context, language Language, query string) (int, error) {
 	if s.DB == nil {
 		return 0, ErrorNoDatabase
@@ -121,6 +327,35 @@ func (s Storage) GetProgress(ctx context.Context, language Language, query strin
 	return lastPage, nil
 }
 
+// Progress last fetched page of a search query, -1 once the query is complete
+type Progress struct {
+	Language string `json:"language"`
+	Query    string `json:"query"`
+	LastPage int    `json:"last_page"`
+}
+
+func (s Storage) ListProgress(ctx context.Context) ([]Progress, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListProgress)
+	if err != nil {
+		log.Debugf("Failed to list progress: %s", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progress []Progress
+	for rows.Next() {
+		var p Progress
+		if err = rows.Scan(&p.Language, &p.Query, &p.LastPage); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
 func (s Storage) UpdateProgress(ctx context.Context, language Language, query string, lastPage int) error {
 	if s.DB == nil {
 		return ErrorNoDatabase
@@ -146,6 +381,80 @@ func (s Storage) CodeExistsByHash(ctx context.Context, hash string) (bool, error
 	return exists, nil
 }
 
+// BlobReport result of a blob store consistency check
+type BlobReport struct {
+	Referenced int      // number of blob keys referenced by the code table
+	Orphaned   []string // blobs on disk without a code row
+	Missing    []string // blob keys referenced by a code row but not on disk
+}
+
+// CheckBlobs compares the blob keys in the code table with the blobs on disk.
+// With repair set, orphaned blobs are deleted and code rows with missing blobs
+// are removed, so the files get fetched again.
+func (s Storage) CheckBlobs(ctx context.Context, repair bool) (BlobReport, error) {
+	if s.DB == nil {
+		return BlobReport{}, ErrorNoDatabase
+	}
+	if s.Blobs == nil {
+		return BlobReport{}, ErrorNoBlobStore
+	}
+
+	rows, err := s.DB.QueryContext(ctx, sqlGetBlobKeys)
+	if err != nil {
+		log.Debugf("Failed to get blob keys: %s", err.Error())
+		return BlobReport{}, err
+	}
+	referenced := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			rows.Close()
+			return BlobReport{}, err
+		}
+		referenced[key] = false
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return BlobReport{}, err
+	}
+
+	report := BlobReport{Referenced: len(referenced)}
+	err = s.Blobs.Walk(ctx, func(key string) error {
+		if _, ok := referenced[key]; ok {
+			referenced[key] = true
+			return nil
+		}
+		report.Orphaned = append(report.Orphaned, key)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	for key, found := range referenced {
+		if !found {
+			report.Missing = append(report.Missing, key)
+		}
+	}
+	sort.Strings(report.Missing)
+
+	if !repair {
+		return report, nil
+	}
+
+	for _, key := range report.Orphaned {
+		if err = s.Blobs.Delete(ctx, key); err != nil {
+			return report, err
+		}
+	}
+	for _, key := range report.Missing {
+		if _, err = s.DB.ExecContext(ctx, sqlDeleteCodeByBlob, key); err != nil {
+			log.Debugf("Failed to delete code with missing blob %s: %s", key, err.Error())
+			return report, err
+		}
+	}
+	return report, nil
+}
+
 func (s Storage) queryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
 	if s.DB == nil {
 		return nil
@@ -157,11 +466,24 @@ func (s Storage) tableExists(ctx context.Context, tableName string) (bool, error
 	if s.DB == nil {
 		return false, ErrorNoDatabase
 	}
-	_, err := s.DB.QueryContext(ctx, fmt.Sprintf(sqlGetFirstFromTable, tableName))
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, sqlTableExists, tableName).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (s Storage) columnExists(ctx context.Context, tableName, columnName string) (bool, error) {
+	if s.DB == nil {
+		return false, ErrorNoDatabase
+	}
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, sqlColumnExists, tableName, columnName).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return exists, nil
 }
 
 func (s Storage) dropTables() error {
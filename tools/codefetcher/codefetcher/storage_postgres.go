@@ -0,0 +1,453 @@
+package codefetcher
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	_ "github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	sqlPostgresCreateTableCode = `CREATE TABLE IF NOT EXISTS "code" (
+	"id"	BIGSERIAL PRIMARY KEY,
+	"language"	TEXT NOT NULL,
+	"url"	TEXT NOT NULL,
+	"content"	BYTEA NOT NULL,
+	"hash"	TEXT NOT NULL UNIQUE,
+	"size"	INTEGER NOT NULL DEFAULT 0
+);`
+	sqlPostgresCreateTableProgress = `CREATE TABLE IF NOT EXISTS "progress" (
+	"language"	TEXT NOT NULL,
+	"query"	TEXT NOT NULL,
+	"last_page"	INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY("language", "query")
+);`
+	sqlPostgresCreateTableTombstones = `CREATE TABLE IF NOT EXISTS "tombstones" (
+	"hash"	TEXT NOT NULL PRIMARY KEY,
+	"url"	TEXT NOT NULL,
+	"reason"	TEXT NOT NULL DEFAULT '',
+	"purged_at"	TEXT NOT NULL
+);`
+	sqlPostgresCreateTableSplitConfig = `CREATE TABLE IF NOT EXISTS "split_config" (
+	"id"	INTEGER NOT NULL PRIMARY KEY CHECK ("id" = 1),
+	"train"	DOUBLE PRECISION NOT NULL,
+	"validation"	DOUBLE PRECISION NOT NULL,
+	"test"	DOUBLE PRECISION NOT NULL,
+	"seed"	BIGINT NOT NULL DEFAULT 0
+);`
+	sqlPostgresCreateTableRuns = `CREATE TABLE IF NOT EXISTS "runs" (
+	"id"	BIGSERIAL PRIMARY KEY,
+	"started_at"	TEXT NOT NULL,
+	"finished_at"	TEXT,
+	"version"	TEXT NOT NULL,
+	"arguments"	TEXT NOT NULL,
+	"source"	TEXT NOT NULL,
+	"languages"	TEXT NOT NULL,
+	"queries"	TEXT NOT NULL,
+	"searched"	INTEGER NOT NULL DEFAULT 0,
+	"downloaded"	INTEGER NOT NULL DEFAULT 0,
+	"stored"	INTEGER NOT NULL DEFAULT 0,
+	"duplicates"	INTEGER NOT NULL DEFAULT 0,
+	"skipped"	TEXT NOT NULL DEFAULT '{}',
+	"mismatches"	TEXT NOT NULL DEFAULT '{}',
+	"error"	TEXT NOT NULL DEFAULT ''
+);`
+	sqlPostgresCreateTableBlocklist = `CREATE TABLE IF NOT EXISTS "blocklist" (
+	"kind"	TEXT NOT NULL,
+	"pattern"	TEXT NOT NULL,
+	"reason"	TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY("kind", "pattern")
+);`
+	sqlPostgresAddColumn             = `ALTER TABLE "%s" ADD COLUMN IF NOT EXISTS "%s" %s;`
+	sqlPostgresDropTables            = `DROP TABLE IF EXISTS "code"; DROP TABLE IF EXISTS "progress"; DROP TABLE IF EXISTS "tombstones"; DROP TABLE IF EXISTS "split_config"; DROP TABLE IF EXISTS "runs"; DROP TABLE IF EXISTS "blocklist";`
+	sqlPostgresInsertCode            = `INSERT INTO code (language, url, content, hash, size, source, split, run_id, generated) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (hash) DO NOTHING;`
+	sqlPostgresCountCodes            = `SELECT COUNT(id) FROM code;`
+	sqlPostgresGetCodeSizeByLanguage = `SELECT COALESCE(SUM(size), 0) FROM code WHERE language = $1;`
+	sqlPostgresCodeExists            = `SELECT EXISTS(SELECT 1 FROM code WHERE hash = $1);`
+	sqlPostgresTombstoneExists       = `SELECT EXISTS(SELECT 1 FROM tombstones WHERE hash = $1);`
+	sqlPostgresCountCodesByURL       = `SELECT COUNT(id) FROM code WHERE url ILIKE $1 ESCAPE '\';`
+	sqlPostgresGetCodeSizeByURL      = `SELECT COALESCE(SUM(size), 0) FROM code WHERE url ILIKE $1 ESCAPE '\';`
+	sqlPostgresGetProgress           = `SELECT last_page FROM progress WHERE language = $1 AND query = $2;`
+	// sqlPostgresUpdateProgress reconciles concurrent crawlers like sqlMergeProgress, a lagging
+	// crawler neither reopens a completed query (-1) nor moves it back to an earlier page
+	sqlPostgresUpdateProgress = `INSERT INTO progress (language, query, last_page) VALUES ($1, $2, $3)
+ON CONFLICT (language, query) DO UPDATE SET last_page = CASE
+	WHEN progress.last_page = -1 OR EXCLUDED.last_page = -1 THEN -1
+	ELSE GREATEST(progress.last_page, EXCLUDED.last_page)
+END;`
+	sqlPostgresInsertRun       = `INSERT INTO runs (started_at, version, arguments, source, languages, queries) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;`
+	sqlPostgresFinishRun       = `UPDATE runs SET finished_at = $1, searched = $2, downloaded = $3, stored = $4, duplicates = $5, skipped = $6, mismatches = $7, error = $8 WHERE id = $9;`
+	sqlPostgresInsertBlocklist = `INSERT INTO blocklist (kind, pattern, reason) VALUES ($1, $2, $3)
+ON CONFLICT (kind, pattern) DO UPDATE SET reason = EXCLUDED.reason;`
+	sqlPostgresDeleteBlocklist = `DELETE FROM blocklist WHERE kind = $1 AND pattern = $2;`
+	sqlPostgresListCodeURLs    = `SELECT id, language, url, hash FROM code WHERE id > $1 ORDER BY id LIMIT $2;`
+	sqlPostgresInsertTombstone = `INSERT INTO tombstones (hash, url, reason, purged_at) VALUES ($1, $2, $3, $4) ON CONFLICT (hash) DO NOTHING;`
+	sqlPostgresDeleteCodeByID  = `DELETE FROM code WHERE id = $1;`
+)
+
+// postgresColumnMigrations columns of the code table added after the initial schema, in
+// line with columnMigrations of SQLite
+var postgresColumnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"code", "source", "TEXT NOT NULL DEFAULT '" + SourceGithub + "'"},
+	{"code", "split", "TEXT"},
+	{"code", "run_id", "BIGINT"},
+	{"code", "generated", "BOOLEAN"},
+}
+
+// PostgresStorage stores code files in PostgreSQL, so several crawlers can
+// write to the same database concurrently. Like Storage, inserted code files are
+// assigned a split by the stored split config, tagged by DetectGenerated and with RunID.
+type PostgresStorage struct {
+	DB          *sql.DB
+	RunID       int64
+	SplitConfig *SplitConfig // assigns the split of stored code files, read from the database per insert if nil
+}
+
+func (s PostgresStorage) Init(ctx context.Context) error {
+	for _, query := range []string{sqlPostgresCreateTableCode, sqlPostgresCreateTableProgress, sqlPostgresCreateTableTombstones,
+		sqlPostgresCreateTableSplitConfig, sqlPostgresCreateTableRuns, sqlPostgresCreateTableBlocklist} {
+		_, err := s.DB.ExecContext(ctx, query)
+		if err != nil {
+			log.Debugf("Failed to execute query [%s]: %s", query, err.Error())
+			return err
+		}
+	}
+
+	for _, migration := range postgresColumnMigrations {
+		query := fmt.Sprintf(sqlPostgresAddColumn, migration.table, migration.column, migration.definition)
+		_, err := s.DB.ExecContext(ctx, query)
+		if err != nil {
+			log.Debugf("Failed to execute query [%s]: %s", query, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+func (s PostgresStorage) StoreCodefile(ctx context.Context, language Language, url string, content []byte, hash string) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+
+	if len(hash) == 0 {
+		hash = hex.EncodeToString(sha1.New().Sum(content))
+	}
+
+	config, err := s.GetSplitConfig(ctx)
+	if err != nil {
+		return err
+	}
+	generated := len(DetectGenerated(url, content)) > 0
+
+	var runID any
+	if s.RunID > 0 {
+		runID = s.RunID
+	}
+
+	// duplicate hashes are treated as already stored by ON CONFLICT DO NOTHING
+	_, err = s.DB.ExecContext(ctx, sqlPostgresInsertCode, language.String(), url, content, hash, len(content),
+		SourceGithub, config.Assign(url), runID, generated)
+	if err != nil {
+		log.Debugf("Failed to save codefile VALUES(%s, %s): %s", language.String(), url, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s PostgresStorage) CountCodefiles(ctx context.Context) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var count int
+	err := s.DB.QueryRowContext(ctx, sqlPostgresCountCodes).Scan(&count)
+	if err != nil {
+		log.Debugf("Failed to count codefiles: %s", err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s PostgresStorage) GetTotalCodeSizeByLanguage(ctx context.Context, language Language) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var size int
+	err := s.DB.QueryRowContext(ctx, sqlPostgresGetCodeSizeByLanguage, language.String()).Scan(&size)
+	if err != nil {
+		log.Debugf("Failed to get total code size for language %s: %s", language, err.Error())
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s PostgresStorage) CountCodefilesByRepository(ctx context.Context, repository Repository) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var count int
+	err := s.DB.QueryRowContext(ctx, sqlPostgresCountCodesByURL, likePrefix(repository.URL()+"/")).Scan(&count)
+	if err != nil {
+		log.Debugf("Failed to count codefiles of repository %s: %s", repository, err.Error())
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s PostgresStorage) GetTotalCodeSizeByOwner(ctx context.Context, owner string) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var size int
+	err := s.DB.QueryRowContext(ctx, sqlPostgresGetCodeSizeByURL, likePrefix(githubURL+owner+"/")).Scan(&size)
+	if err != nil {
+		log.Debugf("Failed to get total code size of owner %s: %s", owner, err.Error())
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s PostgresStorage) GetProgress(ctx context.Context, language Language, query string) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var lastPage int
+	err := s.DB.QueryRowContext(ctx, sqlPostgresGetProgress, language.String(), query).Scan(&lastPage)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		log.Debugf("Failed to get progress VALUES(%s, %s): %s", language, query, err.Error())
+		return 0, err
+	}
+	return lastPage, nil
+}
+
+func (s PostgresStorage) UpdateProgress(ctx context.Context, language Language, query string, lastPage int) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	_, err := s.DB.ExecContext(ctx, sqlPostgresUpdateProgress, language.String(), query, lastPage)
+	if err != nil {
+		log.Debugf("Failed to update progress VALUES(%s, %s, %d): %s", language, query, lastPage, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s PostgresStorage) CodeExistsByHash(ctx context.Context, hash string) (bool, error) {
+	if s.DB == nil {
+		return false, ErrorNoDatabase
+	}
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, sqlPostgresCodeExists, hash).Scan(&exists)
+	if err != nil {
+		log.Debugf("Failed to check if code exists: %s", err.Error())
+		return false, err
+	}
+	return exists, nil
+}
+
+// TombstoneExists reports whether a code file with hash was purged and must not be fetched again
+func (s PostgresStorage) TombstoneExists(ctx context.Context, hash string) (bool, error) {
+	if s.DB == nil {
+		return false, ErrorNoDatabase
+	}
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, sqlPostgresTombstoneExists, hash).Scan(&exists)
+	if err != nil {
+		log.Debugf("Failed to check if tombstone exists: %s", err.Error())
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetSplitConfig returns s.SplitConfig or else the stored split config, DefaultSplitConfig if none is stored
+func (s PostgresStorage) GetSplitConfig(ctx context.Context) (SplitConfig, error) {
+	if s.DB == nil {
+		return SplitConfig{}, ErrorNoDatabase
+	}
+	if s.SplitConfig != nil {
+		return *s.SplitConfig, nil
+	}
+	// split_config has no placeholders, so the query of Storage works as well
+	return getSplitConfig(ctx, s.DB)
+}
+
+// StartRun records the start of run, see Storage.StartRun
+func (s PostgresStorage) StartRun(ctx context.Context, run Run) (Run, error) {
+	if s.DB == nil {
+		return run, ErrorNoDatabase
+	}
+	run.StartedAt = time.Now().UTC().Truncate(time.Second)
+
+	values := runValues(run)
+	err := s.DB.QueryRowContext(ctx, sqlPostgresInsertRun, run.StartedAt.Format(time.RFC3339), run.Version,
+		values[0], run.Source, values[1], values[2]).Scan(&run.ID)
+	if err != nil {
+		log.Debugf("Failed to insert run: %s", err.Error())
+	}
+	return run, err
+}
+
+// FinishRun records the end of run along with its summary and error, if any
+func (s PostgresStorage) FinishRun(ctx context.Context, run Run) (Run, error) {
+	if s.DB == nil {
+		return run, ErrorNoDatabase
+	}
+	run.FinishedAt = time.Now().UTC().Truncate(time.Second)
+
+	counts := summaryValues(run.Summary)
+	summary := run.Summary
+	_, err := s.DB.ExecContext(ctx, sqlPostgresFinishRun, run.FinishedAt.Format(time.RFC3339), summary.Searched, summary.Downloaded,
+		summary.Stored, summary.Duplicates, counts[0], counts[1], run.Error, run.ID)
+	if err != nil {
+		log.Debugf("Failed to finish run %d: %s", run.ID, err.Error())
+	}
+	return run, err
+}
+
+func (s PostgresStorage) ListRuns(ctx context.Context) ([]Run, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListRuns)
+	if err != nil {
+		log.Debugf("Failed to list runs: %s", err.Error())
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+// AddBlocklist stores entries in the blocklist table, the reason of existing entries is updated
+func (s PostgresStorage) AddBlocklist(ctx context.Context, entries Blocklist) error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	for _, e := range entries {
+		_, err := s.DB.ExecContext(ctx, sqlPostgresInsertBlocklist, string(e.Kind), e.Pattern, e.Reason)
+		if err != nil {
+			log.Debugf("Failed to add blocklist entry %s: %s", e, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveBlocklist removes entries from the blocklist table, returns the number of removed entries
+func (s PostgresStorage) RemoveBlocklist(ctx context.Context, entries Blocklist) (int, error) {
+	if s.DB == nil {
+		return 0, ErrorNoDatabase
+	}
+	var removed int
+	for _, e := range entries {
+		result, err := s.DB.ExecContext(ctx, sqlPostgresDeleteBlocklist, string(e.Kind), e.Pattern)
+		if err != nil {
+			log.Debugf("Failed to remove blocklist entry %s: %s", e, err.Error())
+			return removed, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+func (s PostgresStorage) GetBlocklist(ctx context.Context) (Blocklist, error) {
+	if s.DB == nil {
+		return nil, ErrorNoDatabase
+	}
+	rows, err := s.DB.QueryContext(ctx, sqlListBlocklist)
+	if err != nil {
+		log.Debugf("Failed to list blocklist: %s", err.Error())
+		return nil, err
+	}
+	return scanBlocklist(rows)
+}
+
+// Purge deletes all stored code files matching blocklist and leaves a tombstone for each,
+// see Storage.Purge
+func (s PostgresStorage) Purge(ctx context.Context, blocklist Blocklist, dryRun bool) (PurgeReport, error) {
+	report := PurgeReport{Languages: make(map[string]int)}
+	if s.DB == nil {
+		return report, ErrorNoDatabase
+	}
+
+	var purge []Codefile
+	var reasons []string
+	var cursor int64
+	for {
+		rows, err := s.DB.QueryContext(ctx, sqlPostgresListCodeURLs, cursor, defaultIterateBatchSize)
+		if err != nil {
+			log.Debugf("Failed to list codefiles: %s", err.Error())
+			return report, err
+		}
+		var count int
+		for rows.Next() {
+			var c Codefile
+			if err = rows.Scan(&c.ID, &c.Language, &c.URL, &c.Hash); err != nil {
+				rows.Close()
+				return report, err
+			}
+			count++
+			cursor = c.ID
+			if reason, ok := blocklist.matchURL(c.URL); ok {
+				purge = append(purge, c)
+				reasons = append(reasons, reason)
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return report, err
+		}
+		if count < defaultIterateBatchSize {
+			break
+		}
+	}
+
+	if !dryRun {
+		if err := s.purgeCodefiles(ctx, purge, reasons); err != nil {
+			return report, err
+		}
+	}
+	report.add(purge)
+	return report, nil
+}
+
+func (s PostgresStorage) purgeCodefiles(ctx context.Context, codefiles []Codefile, reasons []string) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	purgedAt := time.Now().UTC().Format(time.RFC3339)
+	for i, c := range codefiles {
+		if _, err = tx.ExecContext(ctx, sqlPostgresInsertTombstone, c.Hash, c.URL, reasons[i], purgedAt); err != nil {
+			log.Debugf("Failed to insert tombstone %s: %s", c.Hash, err.Error())
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, sqlPostgresDeleteCodeByID, c.ID); err != nil {
+			log.Debugf("Failed to delete codefile %d: %s", c.ID, err.Error())
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s PostgresStorage) dropTables() error {
+	if s.DB == nil {
+		return ErrorNoDatabase
+	}
+	_, err := s.DB.Exec(sqlPostgresDropTables)
+	return err
+}
@@ -31,10 +31,12 @@ var (
 )
 
 func createTempDatabase(t *testing.T) Storage {
+	return openTestDatabase(t, t.TempDir()+testDatabasePath)
+}
 
-	tempDatabasePath := t.TempDir() + testDatabasePath
+func openTestDatabase(t *testing.T, path string) Storage {
 
-	db, err := sql.Open("sqlite", tempDatabasePath)
+	db, err := sql.Open("sqlite", path)
 	if err != nil {
 		t.Fatalf("Error creating database: %v", err)
 	}
@@ -331,3 +333,183 @@ func TestCodeExistsByHash(t *testing.T) {
 		t.Fatalf("Expected code to exist")
 	}
 }
+
+func createTempBlobDatabase(t *testing.T) Storage {
+	s := createTempDatabase(t)
+
+	blobs, err := NewDiskBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating blob store: %v", err)
+	}
+	s.Blobs = blobs
+
+	return s
+}
+
+func TestBlobStorage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempBlobDatabase(t)
+	defer s.DB.Close()
+
+	err := s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	var content string
+	err = s.queryRowContext(ctx, testGetContentQuery).Scan(&content)
+	if err != nil {
+		t.Fatalf("Error querying database: %v", err)
+	}
+	if len(content) != 0 {
+		t.Fatalf("Expected content to be stored outside the database, got '%s'", content)
+	}
+
+	blob, err := s.Blobs.Get(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error reading blob: %v", err)
+	}
+	if !bytes.Equal(blob, testCodefileHelloWorld) {
+		t.Fatalf("Expected blob to be '%s', got '%s'", testCodefileHelloWorld, blob)
+	}
+
+	totalSize, err := s.GetTotalCodeSizeByLanguage(ctx, testLanguage1)
+	if err != nil {
+		t.Fatalf("Error querying database: %v", err)
+	}
+	if totalSize != len(testCodefileHelloWorld) {
+		t.Fatalf("Expected %d bytes, got %d", len(testCodefileHelloWorld), totalSize)
+	}
+
+	exists, err := s.CodeExistsByHash(ctx, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error querying database: %v", err)
+	}
+	if !exists {
+		t.Fatalf("Expected code to exist")
+	}
+}
+
+// metadataBlobStore records the metadata written for each blob
+type metadataBlobStore struct {
+	*DiskBlobStore
+	metadata map[string][]BlobMetadata
+}
+
+func (b metadataBlobStore) PutMetadata(ctx context.Context, key string, metadata BlobMetadata) error {
+	b.metadata[key] = append(b.metadata[key], metadata)
+	return nil
+}
+
+func TestBlobMetadataDuplicate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempBlobDatabase(t)
+	defer s.DB.Close()
+	blobs := metadataBlobStore{DiskBlobStore: s.Blobs.(*DiskBlobStore), metadata: make(map[string][]BlobMetadata)}
+	s.Blobs = blobs
+
+	for _, url := range []string{"http://localhost/main.py", "http://localhost/copy.py"} {
+		err := s.StoreCodefile(ctx, testLanguage1, url, testCodefileHelloWorld, testCodefileHelloWorldHash)
+		if err != nil {
+			t.Fatalf("Error inserting codefile: %v", err)
+		}
+	}
+
+	// the duplicate must not replace the metadata of the stored code file
+	metadata := blobs.metadata[testCodefileHelloWorldHash]
+	if len(metadata) != 1 || metadata[0].URL != "http://localhost/main.py" {
+		t.Fatalf("Expected metadata of main.py only, got %+v", metadata)
+	}
+}
+
+func TestCheckBlobs(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempBlobDatabase(t)
+	defer s.DB.Close()
+
+	err := s.StoreCodefile(ctx, testLanguage1, "http://localhost/main.py", testCodefileHelloWorld, testCodefileHelloWorldHash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+	err = s.StoreCodefile(ctx, testLanguage1, "http://localhost/main2.py", testCodefileHelloWorld2, testCodefileHelloWorld2Hash)
+	if err != nil {
+		t.Fatalf("Error inserting codefile: %v", err)
+	}
+
+	report, err := s.CheckBlobs(ctx, false)
+	if err != nil {
+		t.Fatalf("Error checking blobs: %v", err)
+	}
+	if report.Referenced != 2 || len(report.Orphaned) != 0 || len(report.Missing) != 0 {
+		t.Fatalf("Expected consistent blob store, got %+v", report)
+	}
+
+	orphan := "0123456789abcdef"
+	if err = s.Blobs.Put(ctx, orphan, []byte("orphan")); err != nil {
+		t.Fatalf("Error writing blob: %v", err)
+	}
+	if err = s.Blobs.Delete(ctx, testCodefileHelloWorld2Hash); err != nil {
+		t.Fatalf("Error deleting blob: %v", err)
+	}
+
+	report, err = s.CheckBlobs(ctx, true)
+	if err != nil {
+		t.Fatalf("Error checking blobs: %v", err)
+	}
+	if len(report.Orphaned) != 1 || report.Orphaned[0] != orphan {
+		t.Fatalf("Expected orphaned blob %s, got %v", orphan, report.Orphaned)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != testCodefileHelloWorld2Hash {
+		t.Fatalf("Expected missing blob %s, got %v", testCodefileHelloWorld2Hash, report.Missing)
+	}
+
+	report, err = s.CheckBlobs(ctx, false)
+	if err != nil {
+		t.Fatalf("Error checking blobs: %v", err)
+	}
+	if report.Referenced != 1 || len(report.Orphaned) != 0 || len(report.Missing) != 0 {
+		t.Fatalf("Expected repaired blob store, got %+v", report)
+	}
+}
+
+func TestInitMigratesExistingDatabase(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	// recreate the initial schema without any migrated columns
+	if err := s.dropTables(); err != nil {
+		t.Fatalf("Error dropping tables: %v", err)
+	}
+	for _, query := range []string{sqlCreateTableCode, sqlCreateTableProgress} {
+		if _, err := s.DB.ExecContext(ctx, query); err != nil {
+			t.Fatalf("Error creating table: %v", err)
+		}
+	}
+
+	if err := s.Init(ctx); err != nil {
+		t.Fatalf("Error initializing database: %v", err)
+	}
+	for _, migration := range columnMigrations {
+		exists, err := s.columnExists(ctx, migration.table, migration.column)
+		if err != nil {
+			t.Fatalf("Error querying database: %v", err)
+		}
+		if !exists {
+			t.Fatalf("Column %s.%s not found in database", migration.table, migration.column)
+		}
+	}
+
+	// running the migrations twice must not fail
+	if err := s.Init(ctx); err != nil {
+		t.Fatalf("Error initializing database: %v", err)
+	}
+}
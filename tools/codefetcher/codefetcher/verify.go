@@ -0,0 +1,161 @@
+package codefetcher
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// SkipReasonLanguageMismatch skip reason of code files detected as a language that is not available
+const SkipReasonLanguageMismatch = "language mismatch"
+
+// detectedObjectiveC name of the language of C family files with Objective-C keywords
+const detectedObjectiveC = "Objective-C"
+
+// modelineLines number of leading and trailing lines searched for editor modelines
+const modelineLines = 5
+
+var (
+	vimModeline   = regexp.MustCompile(`(?:^|\s)(?:vi|vim|ex):.*?\b(?:ft|filetype|syntax)=([\w+#-]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-(.+?)-\*-`)
+
+	// ambiguous C family files, in the spirit of linguist's heuristics for ".h"
+	objectiveCSignals = regexp.MustCompile(`(?m)^\s*(@(interface|class|protocol|property|end|synchronized|selector|implementation)\b|#import\s+.+\.h[">])`)
+	cppSignals        = regexp.MustCompile(`(?m)(^\s*#\s*include\s*<(cstdint|cstdio|cstdlib|cstring|string|vector|map|set|list|array|bitset|queue|stack|deque|memory|algorithm|functional|forward_list|unordered_map|unordered_set|(i|o|io|s|f)stream)>|^\s*template\s*<|^\s*(try|constexpr)\b|^\s*catch\s*\(|^\s*(class|(using\s+)?namespace)\s+\w+|^\s*(private|public|protected):\s*$|\bstd::\w+)`)
+)
+
+// ambiguousExtensions extensions shared by C, C++ and Objective-C, decided by the content
+var ambiguousExtensions = map[string]bool{"c": true, "h": true}
+
+// DetectLanguage returns the language of a code file by its content: the interpreter of a shebang,
+// a vim or emacs modeline, or keywords and includes of C family files with an ambiguous extension.
+// The name may not be an available language, e.g. "Objective-C". Returns an empty name if the
+// content has no signals, signal describes the evidence otherwise.
+func DetectLanguage(filePath string, code []byte) (name, signal string) {
+	if name, interpreter := shebangLanguage(code); len(name) > 0 {
+		return name, "shebang " + interpreter
+	}
+	if name, mode := modelineLanguage(code); len(name) > 0 {
+		return name, "modeline " + mode
+	}
+
+	extension := strings.TrimPrefix(path.Ext(filePath), ".")
+	if !ambiguousExtensions[extension] {
+		return "", ""
+	}
+	if match := objectiveCSignals.Find(code); match != nil {
+		return detectedObjectiveC, fmt.Sprintf("keyword %q", strings.TrimSpace(string(match)))
+	}
+	if match := cppSignals.Find(code); match != nil {
+		return githubLanguageCpp, fmt.Sprintf("keyword %q", strings.TrimSpace(string(match)))
+	}
+	return githubLanguageC, "no C++ or Objective-C keywords"
+}
+
+// shebangLanguage returns the language of the interpreter in the first line of code
+func shebangLanguage(code []byte) (name, interpreter string) {
+	interpreter = shebangInterpreter(code)
+	if len(interpreter) == 0 {
+		return "", ""
+	}
+	for _, candidate := range interpreterNames(interpreter) {
+		if language, ok := languageRegistry.interpreter(candidate); ok {
+			return language.String(), interpreter
+		}
+	}
+	return "", interpreter
+}
+
+// shebangInterpreter returns the interpreter of the shebang line of code, e.g. "python3" for
+// "#!/usr/bin/env python3", empty if code has no shebang
+func shebangInterpreter(code []byte) string {
+	if !bytes.HasPrefix(code, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(code[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// skip options and variable assignments, e.g. "env -S PYTHONPATH=. python3 -u"
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+	return interpreter
+}
+
+// interpreterNames returns the names an interpreter is looked up by, the name itself and the
+// name without version suffix like "3.11"
+func interpreterNames(interpreter string) []string {
+	if trimmed := strings.TrimRight(interpreter, "0123456789."); trimmed != interpreter && len(trimmed) > 0 {
+		return []string{interpreter, trimmed}
+	}
+	return []string{interpreter}
+}
+
+// modelineLanguage returns the language of a vim or emacs modeline in the first or last lines of
+// code, modes of unknown languages are ignored
+func modelineLanguage(code []byte) (name, mode string) {
+	lines := bytes.Split(code, []byte("\n"))
+	if len(lines) > 2*modelineLines {
+		lines = append(lines[:modelineLines:modelineLines], lines[len(lines)-modelineLines:]...)
+	}
+
+	for _, line := range lines {
+		if match := vimModeline.FindSubmatch(line); match != nil {
+			mode = string(match[1])
+		} else if match = emacsModeline.FindSubmatch(line); match != nil {
+			// either "-*- c++ -*-" or variables like "-*- mode: c++; coding: utf-8 -*-"
+			mode = strings.TrimSpace(string(match[1]))
+			if strings.Contains(mode, ":") {
+				mode = ""
+				for _, variable := range strings.Split(string(match[1]), ";") {
+					if key, value, ok := strings.Cut(variable, ":"); ok && strings.TrimSpace(strings.ToLower(key)) == "mode" {
+						mode = strings.TrimSpace(value)
+					}
+				}
+			}
+		}
+		if len(mode) == 0 {
+			continue
+		}
+
+		if language, err := ParseLanguage(mode); err == nil {
+			return language.String(), mode
+		}
+		mode = ""
+	}
+	return "", ""
+}
+
+// VerifyLanguage checks the language of a code file with DetectLanguage. Returns the language
+// to store the file under, which is corrected if the detected language is available and accepts
+// the file by Language.ValidFile, e.g. a ".h" file with Objective-C keywords. mismatch is the
+// detected language name if it differs from language, ok is false if the file should be skipped,
+// e.g. "run.py" with a bash shebang.
+func VerifyLanguage(language Language, filePath string, code []byte) (verified Language, mismatch, signal string, ok bool) {
+	name, signal := DetectLanguage(filePath, code)
+	if len(name) == 0 || name == language.String() {
+		return language, "", signal, true
+	}
+	detected, err := ParseLanguage(name)
+	if err != nil || detected.ValidFile(filePath, code) != nil {
+		return language, name, signal, false
+	}
+	return detected, name, signal, true
+}
+
+// LanguageMismatch key of a mismatch in FetchSummary.Mismatches and ImportReport.Mismatches
+func LanguageMismatch(language Language, detected string) string {
+	return language.String() + " -> " + detected
+}
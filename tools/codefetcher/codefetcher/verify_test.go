@@ -0,0 +1,126 @@
+package codefetcher
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		path     string
+		code     string
+		expected string
+	}{
+		{"main.py", string(testCodefileHelloWorld), "Python"},
+		{"run", "#!/usr/bin/env -S PYTHONPATH=. python3.11 -u\nprint(1)\n", "Python"},
+		{"build.py", "#!/bin/sh\necho build\n", "Shell"},
+		{"main.py", "# -*- coding: utf-8 -*-\nprint(1)\n", ""},
+		{"util.h", "/* -*- mode: c++; coding: utf-8 -*- */\nint f();\n", "C++"},
+		{"util.h", "int f();\n" + strings.Repeat("\n", 20) + "// vim: set ts=4 ft=objc:\n", "Objective-C"},
+		{"util.h", "// vim: set ft=unknown:\nint f();\n", "C"},
+		{"util.h", "#ifdef __cplusplus\nextern \"C\" {\n#endif\nint f();\n", "C"},
+		{"util.h", "#include <string>\nint f();\n", "C++"},
+		{"util.h", "namespace util {\nint f();\n}\n", "C++"},
+		{"util.h", "#import <Foundation/Foundation.h>\n@interface Util : NSObject\n@end\n", "Objective-C"},
+		{"util.c", "int f() { return std::max(1, 2); }\n", "C++"},
+		{"util.cpp", "int f();\n", ""},
+		{"main.go", "package main\n", ""},
+	}
+	for i, test := range tests {
+		if name, signal := DetectLanguage(test.path, []byte(test.code)); name != test.expected {
+			t.Errorf("Expected %q for test %d, got %q (%s)", test.expected, i, name, signal)
+		}
+	}
+}
+
+func TestVerifyLanguage(t *testing.T) {
+	c, _ := ParseLanguage("c")
+	cpp, _ := ParseLanguage("cpp")
+
+	verified, mismatch, _, ok := VerifyLanguage(cpp, "util.h", []byte("int f();\n"))
+	if !ok || verified.String() != c.String() || mismatch != c.String() {
+		t.Fatalf("Expected correction to C, got %s, %q, %v", verified, mismatch, ok)
+	}
+	if LanguageMismatch(cpp, mismatch) != "C++ -> C" {
+		t.Fatalf("Unexpected mismatch key %q", LanguageMismatch(cpp, mismatch))
+	}
+
+	verified, mismatch, _, ok = VerifyLanguage(cpp, "util.h", []byte("class A {};\n"))
+	if !ok || verified.String() != cpp.String() || len(mismatch) > 0 {
+		t.Fatalf("Expected C++ to be confirmed, got %s, %q, %v", verified, mismatch, ok)
+	}
+
+	verified, mismatch, _, ok = VerifyLanguage(c, "util.h", []byte("@interface A\n@end\n"))
+	if !ok || verified.String() != "Objective-C" || mismatch != "Objective-C" {
+		t.Fatalf("Expected correction to Objective-C, got %s, %q, %v", verified, mismatch, ok)
+	}
+
+	// detected languages only relabel files they accept, the others are skipped
+	python, _ := ParseLanguage("python")
+	for _, test := range []struct {
+		language Language
+		path     string
+		code     string
+		mismatch string
+	}{
+		{python, "run.py", "#!/bin/bash\nmake\n", "Shell"},
+		{cpp, "x.cpp", "// vim: set ft=python:\nint f();\n", "Python"},
+	} {
+		if _, mismatch, _, ok = VerifyLanguage(test.language, test.path, []byte(test.code)); ok || mismatch != test.mismatch {
+			t.Fatalf("Expected %s of %s to be skipped, got %q, %v", test.mismatch, test.path, mismatch, ok)
+		}
+	}
+	verified, mismatch, _, ok = VerifyLanguage(python, "build", []byte("#!/bin/bash\nmake\n"))
+	if !ok || verified.String() != "Shell" || mismatch != "Shell" {
+		t.Fatalf("Expected correction to Shell, got %s, %q, %v", verified, mismatch, ok)
+	}
+
+	// detected languages missing in the registry can't be stored
+	registry, err := NewLanguageRegistry([]LanguageDefinition{c.Definition(), cpp.Definition()})
+	if err != nil {
+		t.Fatalf("Error creating registry: %v", err)
+	}
+	SetLanguageRegistry(registry)
+	defer SetLanguageRegistry(mustLanguageRegistry(DefaultLanguageDefinitions))
+	if _, mismatch, _, ok = VerifyLanguage(c, "util.h", []byte("@interface A\n@end\n")); ok || mismatch != "Objective-C" {
+		t.Fatalf("Expected Objective-C mismatch, got %q, %v", mismatch, ok)
+	}
+}
+
+func TestImportVerifyLanguage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	s := createTempDatabase(t)
+	defer s.DB.Close()
+
+	const dataset = `{"content": "int f();\n", "lang": "C++", "path": "a.h"}
+{"content": "class A {};\n", "lang": "C", "path": "b.h"}
+{"content": "@interface A\n@end\n", "lang": "C", "path": "c.h"}
+{"content": "#!/bin/bash\nmake\n", "lang": "Python", "path": "run.py"}
+`
+	c, _ := ParseLanguage("c")
+	cpp, _ := ParseLanguage("cpp")
+	python, _ := ParseLanguage("python")
+	report, err := s.Import(ctx, NewJSONLRecordReader(strings.NewReader(dataset)), ImportOptions{
+		Source:         "test-dataset",
+		Languages:      []Language{c, cpp, python},
+		VerifyLanguage: true,
+	})
+	if err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+	if report.Mismatches["C++ -> C"] != 1 || report.Mismatches["C -> C++"] != 1 || report.Mismatches["C -> Objective-C"] != 1 ||
+		report.Mismatches["Python -> Shell"] != 1 {
+		t.Fatalf("Unexpected mismatches %v", report.Mismatches)
+	}
+	if report.Skipped[SkipReasonLanguageFiltered] != 1 || report.Skipped[SkipReasonLanguageMismatch] != 1 {
+		t.Fatalf("Unexpected skipped rows %v", report.Skipped)
+	}
+	for _, language := range []Language{c, cpp} {
+		if count := report.Languages[language.String()]; count == nil || count.Inserted != 1 {
+			t.Fatalf("Expected 1 inserted %s file, got %+v", language, count)
+		}
+	}
+}